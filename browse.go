@@ -0,0 +1,633 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const browseUsage = `Browse the interfaces in a path and their implementers in a terminal UI.
+
+Usage:
+  impl browse -path <path> [flags]
+
+The path is loaded once. Type to fuzzy-search the interfaces.
+
+Keys:
+  up, down   move the selection
+  tab        switch between the interfaces and their implementers
+  enter      show the implementers, or open the selected one in $EDITOR
+  esc        go back to the interfaces, or clear the search
+  ctrl-o     toggle concrete types only
+  ctrl-e     toggle explaining which method provides each interface method
+  ctrl-n     toggle near misses: types with most, but not all, of the methods
+  ctrl-c     quit
+
+Flags:`
+
+// Keys read by readKey. Other keys are the typed character.
+const (
+	keyUp        = "up"
+	keyDown      = "down"
+	keyTab       = "tab"
+	keyEnter     = "enter"
+	keyEsc       = "esc"
+	keyBackspace = "backspace"
+	keyCtrlC     = "ctrl-c"
+	keyCtrlE     = "ctrl-e"
+	keyCtrlN     = "ctrl-n"
+	keyCtrlO     = "ctrl-o"
+)
+
+func browseMain(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("browse", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, browseUsage)
+		fs.PrintDefaults()
+	}
+	addPathFlags(fs)
+	fs.BoolVar(&arg.ConcreteOnly, "concrete-only", false, "start with concrete-only on")
+	addDiagnosticsFlag(fs)
+	addLoadFlags(fs)
+	parseFlags(fs, "impl browse", args)
+
+	if err := applyConfig(fs, "impl browse"); err != nil {
+		fatal(err)
+	}
+	if err := checkBrowseFlags(); err != nil {
+		fatal(err)
+	}
+
+	objects, _, err := loadPaths(ctx, arg.Path, argLoadConfig())
+	if err != nil {
+		fatal(err)
+	}
+	b := newBrowser(objects)
+	b.concreteOnly = arg.ConcreteOnly
+	b.loadEntries()
+
+	t, err := openTerminal()
+	if err != nil {
+		fatal(err)
+	}
+	err = b.run(t, os.Stdin, os.Stdout)
+	t.restore()
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func checkBrowseFlags() error {
+	const cmd = "impl browse"
+	if err := resolveScope(cmd); err != nil {
+		return err
+	}
+	if len(arg.Path) == 0 && !arg.Std {
+		return usageErrorf(cmd, "must specify directory to search (-path flag) or -std.")
+	}
+	return checkDiagnosticsFlag(cmd)
+}
+
+// browser is the state of 'impl browse'.
+type browser struct {
+	idx        *methodIndex
+	interfaces []ObjectIdent // Every interface, sorted by name.
+
+	query   string
+	matches []ObjectIdent // The interfaces that match query, best first.
+	cur     int           // The selected interface in matches.
+
+	inEntries bool          // Whether the entries, rather than the interfaces, are selected.
+	entries   []browseEntry // The implementers or near misses of the selected interface.
+	entryCur  int
+
+	concreteOnly, explain, nearMiss bool
+
+	status string              // A message for the last line, such as an editor failure.
+	lines  map[string][]string // Source lines by filename, for previews.
+}
+
+// browseEntry is an implementer or a near miss of an interface.
+type browseEntry struct {
+	obj  ObjectIdent
+	name string
+	pos  token.Position
+	note string    // The pointer receiver hint.
+	miss *NearMiss // Set for a near miss.
+}
+
+// newBrowser returns a browser of the interfaces in objects, with every
+// interface matching the empty query.
+func newBrowser(objects []ObjectIdent) *browser {
+	b := &browser{idx: newMethodIndex(objects), lines: make(map[string][]string)}
+	seen := make(CharSet)
+	for _, iface := range declaredInterfaces(objects) {
+		if c := NewChar(iface); !seen[c] {
+			seen[c] = true
+			b.interfaces = append(b.interfaces, iface)
+		}
+	}
+	sort.SliceStable(b.interfaces, func(i, j int) bool {
+		return types.TypeString(b.interfaces[i].Type(), nil) < types.TypeString(b.interfaces[j].Type(), nil)
+	})
+	b.search()
+	return b
+}
+
+// search sets matches to the interfaces that fuzzily match query, best
+// first, and selects the first.
+func (b *browser) search() {
+	type match struct {
+		iface ObjectIdent
+		score int
+	}
+	var ms []match
+	for _, iface := range b.interfaces {
+		if score, ok := fuzzyMatch(b.query, types.TypeString(iface.Type(), nil)); ok {
+			ms = append(ms, match{iface, score})
+		}
+	}
+	sort.SliceStable(ms, func(i, j int) bool { return ms[i].score > ms[j].score })
+	b.matches = b.matches[:0]
+	for _, m := range ms {
+		b.matches = append(b.matches, m.iface)
+	}
+	b.cur = 0
+	b.inEntries = false
+	b.loadEntries()
+}
+
+// loadEntries sets entries to the implementers, or near misses, of the
+// selected interface.
+func (b *browser) loadEntries() {
+	b.entries, b.entryCur = nil, 0
+	if len(b.matches) == 0 {
+		return
+	}
+	iface := b.matches[b.cur]
+	if b.nearMiss {
+		for _, nm := range nearMisses(b.idx, iface) {
+			nm := nm
+			if b.concreteOnly && nm.Kind == kindInterface {
+				continue
+			}
+			b.entries = append(b.entries, browseEntry{obj: nm.obj, name: nm.Name, pos: nm.Pos, miss: &nm})
+		}
+		return
+	}
+	eachImplementer(b.idx, []ObjectIdent{iface}, b.concreteOnly, func(_ ObjectIdent, implementers []Implementer) {
+		for _, im := range implementers {
+			ri := im.ResultIdentifier()
+			b.entries = append(b.entries, browseEntry{obj: im.ObjectIdent, name: ri.Name, pos: ri.Pos, note: pointerHint(ri)})
+		}
+	})
+}
+
+// handleKey updates b for the key k. It returns whether to quit, and the
+// position to open in the editor, if any.
+func (b *browser) handleKey(k string) (quit bool, open *token.Position) {
+	b.status = ""
+	switch k {
+	case keyCtrlC:
+		return true, nil
+	case keyUp, keyDown:
+		d := 1
+		if k == keyUp {
+			d = -1
+		}
+		if b.inEntries {
+			b.entryCur = clamp(b.entryCur+d, len(b.entries))
+		} else if len(b.matches) != 0 {
+			b.cur = clamp(b.cur+d, len(b.matches))
+			b.loadEntries()
+		}
+	case keyTab:
+		b.inEntries = !b.inEntries && len(b.entries) != 0
+	case keyEnter:
+		if !b.inEntries {
+			b.inEntries = len(b.entries) != 0
+		} else if len(b.entries) != 0 {
+			pos := b.entries[b.entryCur].pos
+			return false, &pos
+		}
+	case keyEsc:
+		if b.inEntries {
+			b.inEntries = false
+		} else if b.query != "" {
+			b.query = ""
+			b.search()
+		}
+	case keyCtrlO:
+		b.concreteOnly = !b.concreteOnly
+		b.reloadEntries()
+	case keyCtrlE:
+		b.explain = !b.explain
+	case keyCtrlN:
+		b.nearMiss = !b.nearMiss
+		b.reloadEntries()
+	case keyBackspace:
+		if b.query != "" {
+			_, size := utf8.DecodeLastRuneInString(b.query)
+			b.query = b.query[:len(b.query)-size]
+			b.search()
+		}
+	default:
+		if r, size := utf8.DecodeRuneInString(k); size == len(k) && unicode.IsPrint(r) {
+			b.query += k
+			b.search()
+		}
+	}
+	return false, nil
+}
+
+// reloadEntries reloads the entries after a toggle, keeping the focus in
+// the entries if there still are some.
+func (b *browser) reloadEntries() {
+	b.loadEntries()
+	b.inEntries = b.inEntries && len(b.entries) != 0
+}
+
+// clamp returns i limited to [0, n).
+func clamp(i, n int) int {
+	if i >= n {
+		i = n - 1
+	}
+	if i < 0 {
+		i = 0
+	}
+	return i
+}
+
+// run shows b on t until the user quits, reading keys from in and drawing
+// to out.
+func (b *browser) run(t *terminal, in io.Reader, out io.Writer) error {
+	r := bufio.NewReader(in)
+	w := bufio.NewWriter(out)
+	for {
+		rows, cols := t.size()
+		b.render(w, rows, cols)
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		k, err := readKey(r)
+		if err != nil {
+			return err
+		}
+		quit, open := b.handleKey(k)
+		if quit {
+			return nil
+		}
+		if open != nil {
+			t.restore()
+			err := openEditor(*open)
+			if rerr := t.raw(); rerr != nil {
+				return rerr
+			}
+			if err != nil {
+				b.status = err.Error()
+			}
+		}
+	}
+}
+
+// readKey reads a key press from r, which reads a terminal in raw mode.
+func readKey(r *bufio.Reader) (string, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	switch c {
+	case 3, 4:
+		return keyCtrlC, nil
+	case 5:
+		return keyCtrlE, nil
+	case 14:
+		return keyCtrlN, nil
+	case 15:
+		return keyCtrlO, nil
+	case 16:
+		return keyUp, nil
+	case '\t':
+		return keyTab, nil
+	case '\r', '\n':
+		return keyEnter, nil
+	case 8, 127:
+		return keyBackspace, nil
+	case 27:
+		if r.Buffered() == 0 {
+			return keyEsc, nil
+		}
+		// An escape sequence: ESC [ or ESC O, parameters, then a final
+		// byte in the range @ to ~.
+		if next, _ := r.ReadByte(); next != '[' && next != 'O' {
+			return keyEsc, nil
+		}
+		for r.Buffered() > 0 {
+			final, _ := r.ReadByte()
+			if final < '@' || final > '~' {
+				continue
+			}
+			switch final {
+			case 'A':
+				return keyUp, nil
+			case 'B':
+				return keyDown, nil
+			}
+			return "", nil
+		}
+		return "", nil
+	}
+	return string(c), nil
+}
+
+// render draws b to w for a terminal of the size rows by cols.
+func (b *browser) render(w io.Writer, rows, cols int) {
+	var lines []string
+	styled := make(map[int]bool) // Lines to draw in reverse video.
+	add := func(s string, selected bool) {
+		styled[len(lines)] = selected
+		lines = append(lines, s)
+	}
+
+	cursor := "_"
+	if b.inEntries {
+		cursor = ""
+	}
+	add("Search: "+b.query+cursor, false)
+	add(fmt.Sprintf("ctrl-o concrete only: %s   ctrl-e explain: %s   ctrl-n near misses: %s   tab switch   enter open   ctrl-c quit",
+		onOff(b.concreteOnly), onOff(b.explain), onOff(b.nearMiss)), false)
+
+	// Two header lines, three section titles and a status line.
+	body := rows - 6
+	if body < 3 {
+		body = 3
+	}
+	listH, entriesH := body/3, body/3
+	previewH := body - listH - entriesH
+
+	add(fmt.Sprintf("── Interfaces (%d of %d)", len(b.matches), len(b.interfaces)), false)
+	start, end := window(b.cur, len(b.matches), listH)
+	for i := start; i < start+listH; i++ {
+		if i >= end {
+			add("", false)
+			continue
+		}
+		iface := b.matches[i]
+		add(fmt.Sprintf("%s  %s", types.TypeString(iface.Type(), nil), filepath.Base(iface.FileSet.Position(iface.Pos()).String())), i == b.cur && !b.inEntries)
+	}
+
+	title := "Implementers"
+	if b.nearMiss {
+		title = "Near misses"
+	}
+	if len(b.matches) != 0 {
+		title += " of " + types.TypeString(b.matches[b.cur].Type(), nil)
+	}
+	add(fmt.Sprintf("── %s (%d)", title, len(b.entries)), false)
+	start, end = window(b.entryCur, len(b.entries), entriesH)
+	for i := start; i < start+entriesH; i++ {
+		if i >= end {
+			add("", false)
+			continue
+		}
+		e := b.entries[i]
+		add(fmt.Sprintf("%s  %s%s", e.name, filepath.Base(e.pos.String()), e.note), i == b.entryCur && b.inEntries)
+	}
+
+	add("── Preview", false)
+	preview := b.preview()
+	for i := 0; i < previewH; i++ {
+		if i < len(preview) {
+			add(preview[i], false)
+		} else {
+			add("", false)
+		}
+	}
+	add(b.status, false)
+
+	// Draw from the top left, clearing the rest of each line and of the
+	// screen.
+	fmt.Fprint(w, "\x1b[H")
+	for i, l := range lines {
+		if i == rows {
+			break
+		}
+		l = truncate(l, cols)
+		if styled[i] {
+			l = "\x1b[7m" + l + strings.Repeat(" ", cols-utf8.RuneCountInString(l)) + "\x1b[0m"
+		}
+		fmt.Fprint(w, l, "\x1b[K")
+		if i != len(lines)-1 && i != rows-1 {
+			fmt.Fprint(w, "\r\n")
+		}
+	}
+	fmt.Fprint(w, "\x1b[J")
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// window returns the range of the n items to show in h lines so that cur
+// is visible.
+func window(cur, n, h int) (start, end int) {
+	start = cur - h/2
+	if start > n-h {
+		start = n - h
+	}
+	if start < 0 {
+		start = 0
+	}
+	end = start + h
+	if end > n {
+		end = n
+	}
+	return start, end
+}
+
+// truncate returns s cut to at most n characters, with tabs expanded.
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\t", "    ")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// preview returns the lines that describe the selected entry, or the
+// selected interface if no entry is selected: its declaration, then its
+// methods, how it provides the interface's methods if explain is set, or
+// what it lacks if it is a near miss.
+func (b *browser) preview() []string {
+	if len(b.matches) == 0 {
+		return nil
+	}
+	iface := b.matches[b.cur]
+	obj := iface
+	var e *browseEntry
+	if b.inEntries && len(b.entries) != 0 {
+		e = &b.entries[b.entryCur]
+		obj = e.obj
+	}
+
+	pos := obj.FileSet.Position(obj.Pos())
+	lines := []string{pos.String()}
+	lines = append(lines, b.declaration(pos, 12)...)
+	lines = append(lines, "")
+
+	switch {
+	case e != nil && e.miss != nil:
+		if len(e.miss.Missing) != 0 {
+			lines = append(lines, "Missing: "+strings.Join(e.miss.Missing, ", "))
+		}
+		for _, ws := range e.miss.WrongSignature {
+			lines = append(lines, "Wrong signature: "+ws)
+		}
+	case e != nil && b.explain:
+		for _, mm := range explain(obj, iface.Type()) {
+			if mm.Provider == "" {
+				lines = append(lines, fmt.Sprintf("%s <- missing", mm.Method))
+				continue
+			}
+			l := fmt.Sprintf("%s <- %s  %s", mm.Method, mm.Provider, filepath.Base(mm.Pos.String()))
+			if mm.Pointer {
+				l += "  pointer receiver"
+			}
+			if mm.Promoted != "" {
+				l += "  promoted through " + mm.Promoted
+			}
+			lines = append(lines, l)
+		}
+	default:
+		typ := obj.Type()
+		if !types.IsInterface(typ) {
+			typ = types.NewPointer(typ)
+		}
+		mset := types.NewMethodSet(typ)
+		lines = append(lines, fmt.Sprintf("Methods (%d):", mset.Len()))
+		for i := 0; i < mset.Len(); i++ {
+			fn := mset.At(i).Obj()
+			lines = append(lines, fmt.Sprintf("  %s  %s", types.ObjectString(fn, nil), filepath.Base(obj.FileSet.Position(fn.Pos()).String())))
+		}
+	}
+	return lines
+}
+
+// declaration returns at most max source lines of the type declaration at
+// pos, up to the closing brace of a struct or interface.
+func (b *browser) declaration(pos token.Position, max int) []string {
+	src, ok := b.lines[pos.Filename]
+	if !ok {
+		data, err := os.ReadFile(pos.Filename)
+		if err == nil {
+			src = strings.Split(string(data), "\n")
+		}
+		b.lines[pos.Filename] = src
+	}
+	if pos.Line < 1 || pos.Line > len(src) {
+		return nil
+	}
+	var lines []string
+	depth := 0
+	for _, l := range src[pos.Line-1:] {
+		if len(lines) == max {
+			lines = append(lines, "...")
+			break
+		}
+		lines = append(lines, l)
+		depth += strings.Count(l, "{") - strings.Count(l, "}")
+		if depth <= 0 {
+			break
+		}
+	}
+	return lines
+}
+
+// fuzzyMatch reports whether the characters of pattern appear in s in
+// order, ignoring case, and scores the best match: higher for consecutive
+// characters and for characters at the start of a word, such as after a "."
+// or at an upper case letter.
+func fuzzyMatch(pattern, s string) (int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	if len(p) == 0 {
+		return 0, true
+	}
+	rs := []rune(s)
+	best, found := 0, false
+	// Match greedily from each occurrence of the first character, since
+	// a later one may start a word.
+	for start, r := range rs {
+		if unicode.ToLower(r) != p[0] {
+			continue
+		}
+		if score, ok := fuzzyMatchFrom(p, rs, start); ok && (!found || score > best) {
+			best, found = score, true
+		}
+	}
+	return best, found
+}
+
+// fuzzyMatchFrom matches p greedily in rs from index start, as fuzzyMatch
+// does.
+func fuzzyMatchFrom(p, rs []rune, start int) (int, bool) {
+	score, pi, prev := 0, 0, -2
+	for i := start; i < len(rs) && pi < len(p); i++ {
+		r := rs[i]
+		if unicode.ToLower(r) != p[pi] {
+			continue
+		}
+		score++
+		if i == prev+1 {
+			score += 2
+		}
+		if i == 0 || strings.ContainsRune("./_*", rs[i-1]) || unicode.IsUpper(r) && !unicode.IsUpper(rs[i-1]) {
+			score += 3
+		}
+		prev = i
+		pi++
+	}
+	return score, pi == len(p)
+}
+
+// openEditor opens the file at pos in $EDITOR, or vi if it is not set.
+func openEditor(pos token.Position) error {
+	args := editorCommand(os.Getenv("EDITOR"), pos)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v", args[0], err)
+	}
+	return nil
+}
+
+// editorCommand returns the command line that opens editor at pos. editor
+// may include arguments, as in "code -w". An empty or blank editor is vi.
+func editorCommand(editor string, pos token.Position) []string {
+	args := strings.Fields(editor)
+	if len(args) == 0 {
+		args = []string{"vi"}
+	}
+	switch filepath.Base(args[0]) {
+	case "code", "code-insiders", "codium":
+		return append(args, "-g", fmt.Sprintf("%s:%d:%d", pos.Filename, pos.Line, pos.Column))
+	case "subl", "atom":
+		return append(args, fmt.Sprintf("%s:%d:%d", pos.Filename, pos.Line, pos.Column))
+	default:
+		// vi, vim, nvim, emacs, nano and most others.
+		return append(args, fmt.Sprintf("+%d", pos.Line), pos.Filename)
+	}
+}
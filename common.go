@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const commonUsage = `Print the interface with the methods common to several types.
+
+Usage:
+  impl common -type <[*]pkg.Name> -type <[*]pkg.Name> ... -name <Name> -path <path> [flags]
+
+The interface has the exported methods that all the types have with identical
+signatures, using the methods of the pointer for a type that starts with "*".
+Types of other packages than the types' own package are qualified by package
+name, and the declaration starts with their imports. It is followed by the
+interfaces declared in the path that all the types already implement.
+
+Example:
+  impl common -type '*s3.Store' -type '*gcs.Store' -type mem.Store -name Store -path ./storage/...
+
+Flags:`
+
+// Common is the report of impl common.
+type Common struct {
+	Types []string `xml:"Type"`
+	// Declaration is the formatted declaration of the interface with the
+	// methods common to the types.
+	Declaration string
+	Methods     []string `xml:"Method"`
+	// Interfaces are the interfaces declared in the path that all the types
+	// implement, ordered by name.
+	Interfaces []ResultIdentifier `xml:"Interface"`
+}
+
+func commonMain(ctx context.Context, args []string) {
+	var (
+		name     string
+		typeList stringsFlag
+	)
+	fs := flag.NewFlagSet("common", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, commonUsage)
+		fs.PrintDefaults()
+	}
+	fs.Var(&typeList, "type", "type to compare, format: packageName.TypeName, or *packageName.TypeName for the methods of the pointer; repeatable")
+	fs.StringVar(&name, "name", "", "name of the interface")
+	addPathFlags(fs)
+	fs.StringVar(&arg.Format, "format", "plain", "output format, should be one of: {plain,json,xml}")
+	addPathsFlag(fs)
+	addDiagnosticsFlag(fs)
+	addLoadFlags(fs)
+	parseFlags(fs, "impl common", args)
+
+	if err := applyConfig(fs, "impl common"); err != nil {
+		fatal(err)
+	}
+	if err := checkCommonFlags(typeList, name); err != nil {
+		fatal(err)
+	}
+
+	objects, pkgs, err := loadPaths(ctx, arg.Path, argLoadConfig())
+	if err != nil {
+		fatal(err)
+	}
+	c, err := commonInterface(objects, typeList, name, importPaths(pkgs))
+	if err != nil {
+		fatal(err)
+	}
+	outputCommon(os.Stdout, c, arg.Format)
+	if len(c.Methods) == 0 {
+		os.Exit(exitNoneFound)
+	}
+}
+
+func checkCommonFlags(typeList []string, name string) error {
+	const cmd = "impl common"
+	if err := resolveScope(cmd); err != nil {
+		return err
+	}
+	switch {
+	case len(arg.Path) == 0 && !arg.Std:
+		return usageErrorf(cmd, "must specify directory to search (-path flag) or -std.")
+	case len(typeList) < 2:
+		return usageErrorf(cmd, "must specify at least two types (-type flag).")
+	case !token.IsIdentifier(name):
+		return usageErrorf(cmd, "must specify the name of the interface, an identifier (-name flag).")
+	case !contains([]string{"plain", "json", "xml"}, arg.Format):
+		return usageErrorf(cmd, "output format should be one of: {plain,json,xml}")
+	}
+	for _, t := range typeList {
+		if len(strings.Split(strings.TrimPrefix(t, "*"), ".")) != 2 {
+			return usageErrorf(cmd, "type %q should be in format: packageName.TypeName", t)
+		}
+	}
+	if err := checkPathsFlag(cmd); err != nil {
+		return err
+	}
+	return checkDiagnosticsFlag(cmd)
+}
+
+// commonInterface returns the Common of the types named in typeList, which
+// are found in objects, with the interface named name. paths has the import
+// paths of the loaded packages, as returned by importPaths.
+func commonInterface(objects []ObjectIdent, typeList []string, name string, paths map[*types.Package]string) (Common, error) {
+	var (
+		c    Common
+		typs []ObjectIdent
+		ptrs []bool
+	)
+	for _, t := range typeList {
+		typ, ptr, err := findType(objects, t)
+		if err != nil {
+			return Common{}, err
+		}
+		typs, ptrs = append(typs, typ), append(ptrs, ptr)
+		c.Types = append(c.Types, t)
+	}
+
+	ms := commonMethods(typs, ptrs)
+	for _, m := range ms {
+		c.Methods = append(c.Methods, m.Name())
+	}
+	doc := fmt.Sprintf("%s has the methods common to %s.", name, joinAnd(c.Types))
+	src, err := interfaceDecl(name, doc, ms, nil, commonQualifier(typs, paths))
+	if err != nil {
+		return Common{}, err
+	}
+	c.Declaration = string(src)
+
+	for _, iface := range declaredInterfaces(objects) {
+		if iface.Type().Underlying().(*types.Interface).NumMethods() == 0 {
+			continue // Every type implements it.
+		}
+		if implementedByAll(iface, typs, ptrs) {
+			c.Interfaces = append(c.Interfaces, NewResultIdentifier(iface))
+		}
+	}
+	sort.SliceStable(c.Interfaces, func(i, j int) bool {
+		return less(c.Interfaces[i].sortItem(), c.Interfaces[j].sortItem(), "name")
+	})
+	return c, nil
+}
+
+// commonMethods returns the exported methods of typs[0], or of the pointer to
+// it if ptrs[0] is true, that every other type in typs has with an identical
+// signature, ordered by name.
+func commonMethods(typs []ObjectIdent, ptrs []bool) []*types.Func {
+	ms, _ := typeMethods(typs[0], ptrs[0], nil)
+	var common []*types.Func
+	for _, m := range ms {
+		shared := true
+		for i, typ := range typs[1:] {
+			sel := methodSetOf(typ, ptrs[i+1]).Lookup(m.Pkg(), m.Name())
+			if sel == nil || !types.Identical(sel.Obj().Type(), m.Type()) {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			common = append(common, m)
+		}
+	}
+	return common
+}
+
+// implementedByAll reports whether each type in typs, or the pointer to it if
+// the corresponding ptrs value is true, implements iface.
+func implementedByAll(iface ObjectIdent, typs []ObjectIdent, ptrs []bool) bool {
+	for i, typ := range typs {
+		im, ok := implementation(typ, iface)
+		if !ok || (im.Receiver == receiverPointer && !ptrs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// commonQualifier returns the qualifier for the declaration of the common
+// interface of typs: types are not qualified in the package of typs if they
+// are all in the same package, and are qualified by package name otherwise.
+func commonQualifier(typs []ObjectIdent, paths map[*types.Package]string) *importQualifier {
+	pkg := typs[0].Pkg()
+	for _, typ := range typs[1:] {
+		if typ.Pkg() != pkg {
+			return newImportQualifier(nil, paths)
+		}
+	}
+	return newImportQualifier(pkg, paths)
+}
+
+// joinAnd joins the elements of a, which must not be empty, as a list in
+// English: "a", "a and b", "a, b and c".
+func joinAnd(a []string) string {
+	if len(a) == 1 {
+		return a[0]
+	}
+	return strings.Join(a[:len(a)-1], ", ") + " and " + a[len(a)-1]
+}
+
+// outputCommon writes c to w in the specified format.
+func outputCommon(w io.Writer, c Common, format string) {
+	switch format {
+	case "plain":
+		io.WriteString(w, c.Declaration)
+		if len(c.Interfaces) == 0 {
+			return
+		}
+		style := pathStyle(format)
+		fmt.Fprintf(w, "\n// Implemented by all the types:\n")
+		for _, ri := range c.Interfaces {
+			fmt.Fprintf(w, "//\t%s %s\n", ri.Name, displayPos(ri.Pos, style))
+		}
+	case "json":
+		b, err := json.MarshalIndent(c, "", "  ")
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Fprintf(w, "%s\n", b)
+	case "xml":
+		b, err := xml.MarshalIndent(c, "", "  ")
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Fprintf(w, "%s\n", b)
+	}
+}
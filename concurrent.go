@@ -1,27 +1,62 @@
 package main
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
-// converge forwards into out each value received from the supplied channels.
-// out is closed once all values have been sent.
-func converge(chs []<-chan ObjectIdent) (out <-chan ObjectIdent) {
-	var wg sync.WaitGroup
-	wg.Add(len(chs))
-	x := make(chan ObjectIdent)
+// parallel calls fn(ctx, i) for each i in [0, n), using at most jobs
+// goroutines. The calls are started in increasing order of i, so a call may
+// wait for the result of a call with a smaller i.
+//
+// After the first error, or once ctx is done, no more calls are started and
+// the ctx passed to the running calls is cancelled. parallel returns the
+// first error, or ctx.Err(), once every goroutine it started has returned.
+func parallel(ctx context.Context, jobs, n int, fn func(ctx context.Context, i int) error) error {
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs > n {
+		jobs = n
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	for _, c := range chs {
-		go func(c <-chan ObjectIdent) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex // Guards next and firstErr.
+		next     int
+		firstErr error
+	)
+	wg.Add(jobs)
+	for w := 0; w < jobs; w++ {
+		go func() {
 			defer wg.Done()
-			for val := range c {
-				x <- val
+			for {
+				mu.Lock()
+				i := next
+				next++
+				stop := firstErr != nil
+				mu.Unlock()
+				if stop || i >= n || ctx.Err() != nil {
+					return
+				}
+				if err := fn(ctx, i); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					cancel()
+					return
+				}
 			}
-		}(c)
+		}()
 	}
+	wg.Wait()
 
-	go func() {
-		wg.Wait()
-		close(x)
-	}()
-
-	return x
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
 }
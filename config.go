@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// configName is the name of the project config file, which is looked for in
+// the working directory and then in each parent directory.
+const configName = ".impl.json"
+
+const runUsage = `Run a named query from the project config file.
+
+Usage:
+  impl run <name> [flags]
+
+The query's flags are applied to its command, then the config file's default
+flags. Flags on the command line override both. The flags are those of the
+query's command; run 'impl run <name> -h' to list them.`
+
+const configUsage = `Inspect the project config file.
+
+Usage:
+  impl config show
+
+show prints the config file that applies in the working directory, with the
+default flags and the flags of each named query resolved as they would be
+applied, as JSON.`
+
+func init() {
+	// Registered here rather than in the commands literal, since run
+	// looks up the command it runs in commands.
+	commands["run"] = runMain
+	commands["config"] = configMain
+}
+
+// Config is a project config file. It sets default flag values for every
+// command, and holds named queries for 'impl run'.
+//
+// Flags are keyed by flag name, without the leading "-". A value is a string,
+// number or boolean, or an array of them for a repeatable flag such as
+// exclude. A path or scope in the file is relative to the file's directory.
+type Config struct {
+	File     string `json:"-"`
+	Defaults map[string]flagValues
+	Queries  map[string]Query
+}
+
+// Query is a named query in a Config: the command to run, "" for the
+// default command, and its flags. In the file, the command is the "command"
+// key and the other keys are flags.
+type Query struct {
+	Command string `json:",omitempty"`
+	Flags   map[string]flagValues
+}
+
+func (q *Query) UnmarshalJSON(b []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	q.Flags = make(map[string]flagValues)
+	for k, v := range raw {
+		if k == "command" {
+			if err := json.Unmarshal(v, &q.Command); err != nil {
+				return fmt.Errorf("command: %v", err)
+			}
+			continue
+		}
+		var vs flagValues
+		if err := json.Unmarshal(v, &vs); err != nil {
+			return fmt.Errorf("flag %s: %v", k, err)
+		}
+		q.Flags[k] = vs
+	}
+	return nil
+}
+
+// flagValues are the values to set a flag to. Each is passed to
+// flag.Value.Set, so a repeatable flag gets every value.
+type flagValues []string
+
+func (v *flagValues) UnmarshalJSON(b []byte) error {
+	var list []json.RawMessage
+	if err := json.Unmarshal(b, &list); err != nil {
+		list = []json.RawMessage{b}
+	}
+	*v = nil
+	for _, e := range list {
+		var s string
+		if err := json.Unmarshal(e, &s); err == nil {
+			*v = append(*v, s)
+			continue
+		}
+		var x interface{}
+		if err := json.Unmarshal(e, &x); err != nil {
+			return err
+		}
+		switch x.(type) {
+		case bool, float64:
+			// Numbers and booleans are set by their JSON text.
+			*v = append(*v, string(e))
+		default:
+			return fmt.Errorf("unsupported flag value %s", e)
+		}
+	}
+	return nil
+}
+
+// queryNames returns the names of the queries in c, sorted.
+func (c *Config) queryNames() []string {
+	var names []string
+	for name := range c.Queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// findConfig returns the config file in dir or its nearest ancestor that has
+// one, or nil if there is none.
+func findConfig(dir string) (*Config, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		file := filepath.Join(dir, configName)
+		if _, err := os.Stat(file); err == nil {
+			return readConfig(file)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// readConfig reads the config file, resolving its paths against the file's
+// directory.
+func readConfig(file string) (*Config, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, wrapErr("failed to read config file", err)
+	}
+	c := &Config{File: file}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, wrapErr("invalid config file "+file, err)
+	}
+	dir := filepath.Dir(file)
+	resolveConfigPaths(c.Defaults, dir)
+	for _, q := range c.Queries {
+		resolveConfigPaths(q.Flags, dir)
+	}
+	return c, nil
+}
+
+// resolveConfigPaths makes the path, scope and paths-from flags in flags
+// relative to dir rather than to the working directory. Only scope values
+// are comma-separated lists; a path may contain commas.
+func resolveConfigPaths(flags map[string]flagValues, dir string) {
+	resolve := func(e string) string {
+		if e != "" && e != stdScope && e != "-" && !filepath.IsAbs(e) {
+			// Join keeps a trailing "...".
+			e = filepath.Join(dir, e)
+		}
+		return e
+	}
+	for _, name := range []string{"path", "scope", "paths-from"} {
+		vs := flags[name]
+		for i, v := range vs {
+			if name != "scope" {
+				vs[i] = resolve(v)
+				continue
+			}
+			var elems []string
+			for _, e := range strings.Split(v, ",") {
+				elems = append(elems, resolve(strings.TrimSpace(e)))
+			}
+			vs[i] = strings.Join(elems, ",")
+		}
+	}
+}
+
+// runQuery is the query that 'impl run' is running, if any.
+var runQuery *Query
+
+// applyConfig sets the flags in fs that were not set on the command line
+// from the query being run, then from the defaults in the config file found
+// from the working directory. Flags that fs does not define are ignored, so
+// that the defaults can hold the flags of every command. cmd is the name of
+// the command for the help message.
+func applyConfig(fs *flag.FlagSet, cmd string) error {
+	c, err := findConfig(".")
+	if err != nil {
+		return err
+	}
+	var layers []map[string]flagValues
+	if runQuery != nil {
+		layers = append(layers, runQuery.Flags)
+	}
+	if c != nil {
+		layers = append(layers, c.Defaults)
+	}
+	return setFlags(fs, cmd, layers...)
+}
+
+// alternativeFlags are flags that name the same setting in different ways.
+// Once one is set, a lower layer of config does not set the others.
+var alternativeFlags = map[string][]string{
+	"path":       {"scope", "paths-from"},
+	"scope":      {"path", "paths-from"},
+	"paths-from": {"path", "scope"},
+}
+
+// setFlags sets each flag in fs that is not set yet to its values in the
+// first of layers that has it. Flags that fs does not define are ignored.
+func setFlags(fs *flag.FlagSet, cmd string, layers ...map[string]flagValues) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, flags := range layers {
+		var setHere []string
+		for name, vs := range flags {
+			if set[name] || fs.Lookup(name) == nil || anySet(set, alternativeFlags[name]) {
+				continue
+			}
+			setHere = append(setHere, name)
+			for _, v := range vs {
+				if err := fs.Set(name, v); err != nil {
+					return usageErrorf(cmd, "invalid value %q for flag -%s in %s: %v", v, name, configName, err)
+				}
+			}
+		}
+		for _, name := range setHere {
+			set[name] = true
+		}
+	}
+	return nil
+}
+
+// mergeFlags returns the flags of layers as setFlags applies them: each flag
+// has its values in the first of layers that has it, unless an earlier layer
+// has one of its alternativeFlags.
+func mergeFlags(layers ...map[string]flagValues) map[string]flagValues {
+	merged := make(map[string]flagValues)
+	set := make(map[string]bool)
+	for _, flags := range layers {
+		var setHere []string
+		for name, vs := range flags {
+			if set[name] || anySet(set, alternativeFlags[name]) {
+				continue
+			}
+			setHere = append(setHere, name)
+			merged[name] = vs
+		}
+		for _, name := range setHere {
+			set[name] = true
+		}
+	}
+	return merged
+}
+
+// anySet reports whether set has any of names.
+func anySet(set map[string]bool, names []string) bool {
+	for _, name := range names {
+		if set[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func runMain(ctx context.Context, args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, runUsage)
+		os.Exit(exitUsage)
+	}
+	c, err := findConfig(".")
+	if err != nil {
+		fatal(err)
+	}
+	if c == nil {
+		fatal(usageErrorf("impl run", "no %s found in the working directory or its parents", configName))
+	}
+	q, ok := c.Queries[args[0]]
+	if !ok {
+		fatal(usageErrorf("impl run", "no query named %s in %s; queries: %s", args[0], c.File, strings.Join(c.queryNames(), ", ")))
+	}
+	runQuery = &q
+	if q.Command == "" {
+		implMain(ctx, args[1:])
+		return
+	}
+	cmd, ok := commands[q.Command]
+	if !ok || q.Command == "run" || q.Command == "config" {
+		fatal(usageErrorf("impl run", "query %s has unknown command %s", args[0], q.Command))
+	}
+	cmd(ctx, args[1:])
+}
+
+func configMain(ctx context.Context, args []string) {
+	if len(args) != 1 || args[0] != "show" {
+		fmt.Fprintln(os.Stderr, configUsage)
+		os.Exit(exitUsage)
+	}
+	c, err := findConfig(".")
+	if err != nil {
+		fatal(err)
+	}
+	if c == nil {
+		fatal(usageErrorf("impl config", "no %s found in the working directory or its parents", configName))
+	}
+
+	// Each query is shown with its flags merged over the defaults, as 'impl
+	// run' applies them before the command line.
+	resolved := struct {
+		File     string
+		Defaults map[string]flagValues
+		Queries  map[string]Query
+	}{c.File, c.Defaults, make(map[string]Query)}
+	for name, q := range c.Queries {
+		resolved.Queries[name] = Query{q.Command, mergeFlags(q.Flags, c.Defaults)}
+	}
+	b, err := json.MarshalIndent(resolved, "", "  ")
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("%s\n", b)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// diagramFormats are the output formats that render a Graph as a diagram.
+var diagramFormats = []string{"dot", "mermaid", "plantuml"}
+
+// writeDiagram writes g to w as a diagram in format, which should be one of
+// diagramFormats. Nodes are clustered by their Group, or by package if they
+// are not grouped, and pointer-receiver-only implementers and their edges are
+// drawn dashed.
+func writeDiagram(w io.Writer, g Graph, format string) error {
+	bw := bufio.NewWriter(w)
+	switch format {
+	case "dot":
+		writeDot(bw, g)
+	case "mermaid":
+		writeMermaid(bw, g)
+	case "plantuml":
+		writePlantUML(bw, g)
+	}
+	return bw.Flush()
+}
+
+// packages returns the nodes of g clustered by their Group, or by package if
+// they are not grouped, along with the clusters in the order they are first
+// seen.
+func packages(g Graph) (order []string, nodes map[string][]Node) {
+	nodes = make(map[string][]Node)
+	for _, n := range g.Nodes {
+		key := n.Group
+		if key == "" {
+			key = n.Package
+		}
+		if _, ok := nodes[key]; !ok {
+			order = append(order, key)
+		}
+		nodes[key] = append(nodes[key], n)
+	}
+	return order, nodes
+}
+
+func writeDot(w io.Writer, g Graph) {
+	fmt.Fprintln(w, "digraph impl {")
+	fmt.Fprintln(w, "\trankdir=BT;")
+	order, nodes := packages(g)
+	for i, pkg := range order {
+		fmt.Fprintf(w, "\tsubgraph cluster_%d {\n", i)
+		fmt.Fprintf(w, "\t\tlabel=%s;\n", strconv.Quote(pkg))
+		for _, n := range nodes[pkg] {
+			shape := "box"
+			if n.Kind == kindInterface {
+				shape = "ellipse"
+			}
+			style := ""
+			if n.PointerOnly {
+				style = ", style=dashed"
+			}
+			fmt.Fprintf(w, "\t\tn%d [label=%s, shape=%s%s];\n", n.ID, strconv.Quote(n.Name), shape, style)
+		}
+		fmt.Fprintln(w, "\t}")
+	}
+	for _, e := range g.Edges {
+		attrs := "label=" + strconv.Quote(e.Kind)
+		switch {
+		case e.Kind == edgeEmbeds:
+			attrs += ", arrowhead=onormal"
+		case e.PointerOnly:
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(w, "\tn%d -> n%d [%s];\n", e.From, e.To, attrs)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func writeMermaid(w io.Writer, g Graph) {
+	fmt.Fprintln(w, "flowchart BT")
+	order, nodes := packages(g)
+	for i, pkg := range order {
+		fmt.Fprintf(w, "\tsubgraph p%d [%s]\n", i, strconv.Quote(pkg))
+		for _, n := range nodes[pkg] {
+			if n.Kind == kindInterface {
+				fmt.Fprintf(w, "\t\tn%d([%s])\n", n.ID, strconv.Quote(n.Name))
+			} else {
+				fmt.Fprintf(w, "\t\tn%d[%s]\n", n.ID, strconv.Quote(n.Name))
+			}
+		}
+		fmt.Fprintln(w, "\tend")
+	}
+	for _, e := range g.Edges {
+		arrow := "-->"
+		switch {
+		case e.Kind == edgeEmbeds:
+			arrow = "==>"
+		case e.PointerOnly:
+			arrow = "-.->"
+		}
+		fmt.Fprintf(w, "\tn%d %s|%s| n%d\n", e.From, arrow, e.Kind, e.To)
+	}
+	fmt.Fprintln(w, "\tclassDef pointer stroke-dasharray: 5 5")
+	for _, n := range g.Nodes {
+		if n.PointerOnly {
+			fmt.Fprintf(w, "\tclass n%d pointer\n", n.ID)
+		}
+	}
+}
+
+func writePlantUML(w io.Writer, g Graph) {
+	fmt.Fprintln(w, "@startuml")
+	order, nodes := packages(g)
+	for _, pkg := range order {
+		fmt.Fprintf(w, "package %s {\n", strconv.Quote(pkg))
+		for _, n := range nodes[pkg] {
+			switch {
+			case n.Kind == kindInterface:
+				fmt.Fprintf(w, "\tinterface %s as n%d\n", strconv.Quote(n.Name), n.ID)
+			case n.PointerOnly:
+				fmt.Fprintf(w, "\tclass %s as n%d <<pointer receiver>>\n", strconv.Quote(n.Name), n.ID)
+			default:
+				fmt.Fprintf(w, "\tclass %s as n%d\n", strconv.Quote(n.Name), n.ID)
+			}
+		}
+		fmt.Fprintln(w, "}")
+	}
+	for _, e := range g.Edges {
+		switch {
+		case e.Kind == edgeEmbeds:
+			fmt.Fprintf(w, "n%d --|> n%d : %s\n", e.From, e.To, e.Kind)
+		case e.PointerOnly:
+			fmt.Fprintf(w, "n%d ..|> n%d : %s (pointer receiver)\n", e.From, e.To, e.Kind)
+		default:
+			fmt.Fprintf(w, "n%d ..|> n%d : %s\n", e.From, e.To, e.Kind)
+		}
+	}
+	fmt.Fprintln(w, "@enduml")
+}
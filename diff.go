@@ -0,0 +1,380 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const diffUsage = `Compare the implementers of the interfaces in two source trees.
+
+Usage:
+  impl diff -base <dir-or-git-rev> -head <dir-or-git-rev> [-path ./...] [flags]
+
+Each side is a directory, or a git revision of the repository in the working
+directory, which is exported to a temporary directory. -path is resolved in
+each side: relative to the directory, or to the working directory's place in
+the repository. The same query is run on both sides, and for each interface
+the implementers that were added or removed are reported, and those that
+changed between implementing it only through *T and through T.
+
+Example:
+  impl diff -base main -head . -path ./service/...
+
+Flags:`
+
+// InterfaceDiff is the change in the implementers of an interface between
+// the base and the head. The positions are relative to the root of their
+// side.
+type InterfaceDiff struct {
+	// Interface is the interface in the head, or in the base if the head
+	// does not have it.
+	Interface ResultIdentifier
+	Added     []ResultIdentifier `json:",omitempty" xml:",omitempty"`
+	Removed   []ResultIdentifier `json:",omitempty" xml:",omitempty"`
+	// Changed are the implementers in the head whose Receiver changed to
+	// or from "*T".
+	Changed []ReceiverChange `json:",omitempty" xml:",omitempty"`
+}
+
+// ReceiverChange is an implementer whose Receiver changed.
+type ReceiverChange struct {
+	ResultIdentifier
+	BaseReceiver string
+}
+
+func diffMain(ctx context.Context, args []string) {
+	var base, head string
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, diffUsage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&base, "base", "", "directory or git revision to compare from")
+	fs.StringVar(&head, "head", "", "directory or git revision to compare to")
+	fs.Var(&arg.Path, "path", "path to search in each side, relative to its root; a directory ending in /... is searched recursively; repeatable (default ./...)")
+	fs.StringVar(&arg.Interface, "interface", "", "only compare the implementers of this interface, format: packageName.interfaceName")
+	fs.StringVar(&arg.Format, "format", "plain", "output format, should be one of: {plain,json,xml}")
+	fs.BoolVar(&arg.ConcreteOnly, "concrete-only", false, "compare concrete implementers only")
+	addDiagnosticsFlag(fs)
+	addLoadFlags(fs)
+	parseFlags(fs, "impl diff", args)
+
+	if err := applyConfig(fs, "impl diff"); err != nil {
+		fatal(err)
+	}
+	if err := checkDiffFlags(base, head); err != nil {
+		fatal(err)
+	}
+	if len(arg.Path) == 0 {
+		arg.Path = listFlag{"./..."}
+	}
+
+	var sides [2][]Result
+	for i, spec := range []string{base, head} {
+		root, cleanup, err := resolveSide(ctx, ".", spec)
+		if err != nil {
+			fatal(err)
+		}
+		sides[i], err = sideResults(ctx, root)
+		cleanup()
+		if err != nil {
+			fatal(err)
+		}
+	}
+	if arg.Interface != "" && len(sides[0]) == 0 && len(sides[1]) == 0 {
+		fatal(&InterfaceNotFoundError{Name: arg.Interface})
+	}
+	diffs := diffResults(sides[0], sides[1])
+	outputDiff(os.Stdout, diffs, arg.Format)
+	if len(diffs) == 0 {
+		os.Exit(exitNoneFound)
+	}
+}
+
+func checkDiffFlags(base, head string) error {
+	const cmd = "impl diff"
+	switch {
+	case base == "" || head == "":
+		return usageErrorf(cmd, "must specify the sides to compare (-base and -head flags).")
+	case arg.Std || len(arg.Scope) != 0:
+		return usageErrorf(cmd, "-std and -scope are not supported; the path is searched in each side.")
+	case arg.Interface != "" && len(strings.Split(arg.Interface, ".")) != 2:
+		return usageErrorf(cmd, "interface name should be in format: packageName.interfaceName (-interface flag).")
+	case !contains([]string{"plain", "json", "xml"}, arg.Format):
+		return usageErrorf(cmd, "output format should be one of: {plain,json,xml}")
+	}
+	for _, p := range arg.Path {
+		if filepath.IsAbs(p) {
+			return usageErrorf(cmd, "path must be relative to the root of each side (-path flag).")
+		}
+	}
+	return checkDiagnosticsFlag(cmd)
+}
+
+// sideResults returns the Results of the query in the flags for the side
+// rooted at root, with positions relative to root. A path that does not
+// exist in the side is skipped, so that added and deleted packages can be
+// compared.
+func sideResults(ctx context.Context, root string) ([]Result, error) {
+	var paths []string
+	for _, p := range arg.Path {
+		path := filepath.Join(root, p)
+		dir, _ := recursiveRoot(path)
+		if dir == "" {
+			dir = path
+		}
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	objects, _, err := loadPaths(ctx, paths, argLoadConfig())
+	if err != nil {
+		return nil, err
+	}
+	interfaces := declaredInterfaces(objects)
+	if arg.Interface != "" {
+		interfaces = filterInterfaces(objects, arg.Interface)
+	}
+	results := implementersOf(newMethodIndex(objects), interfaces, arg.ConcreteOnly)
+	rel := func(ri *ResultIdentifier) {
+		if r, err := filepath.Rel(root, ri.Pos.Filename); err == nil {
+			ri.Pos.Filename, ri.End.Filename = r, r
+		}
+	}
+	for i := range results {
+		rel(&results[i].Interface)
+		for j := range results[i].Implementers {
+			rel(&results[i].Implementers[j])
+		}
+	}
+	return results, nil
+}
+
+// resolveSide returns the root directory of the side spec: spec itself if
+// it is a directory, or else a temporary directory that dir's subtree in
+// the git revision spec is exported to. cleanup removes the export.
+func resolveSide(ctx context.Context, dir, spec string) (root string, cleanup func(), err error) {
+	if info, err := os.Stat(spec); err == nil && info.IsDir() {
+		return spec, func() {}, nil
+	}
+	if _, err := git(ctx, dir, "rev-parse", "--verify", "--quiet", spec+"^{commit}"); err != nil {
+		return "", nil, usageErrorf("impl diff", "%s is neither a directory nor a git revision", spec)
+	}
+	tmp, err := os.MkdirTemp("", "impl-diff-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(tmp) }
+	if err := exportRev(ctx, dir, spec, tmp); err != nil {
+		cleanup()
+		return "", nil, wrapErr("failed to export "+spec, err)
+	}
+	return tmp, cleanup, nil
+}
+
+// exportRev writes the files below dir in the git revision rev of its
+// repository to the directory to. git archive run in a subdirectory of the
+// repository exports only that subdirectory.
+func exportRev(ctx context.Context, dir, rev, to string) error {
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "archive", "--format=tar", rev)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if err := untar(out, to); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// untar writes the directories and regular files in the tar stream r below
+// the directory to. Other entries, such as symbolic links, are skipped.
+func untar(r io.Reader, to string) error {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Join(to, filepath.FromSlash(h.Name))
+		if !strings.HasPrefix(name, filepath.Clean(to)+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path in archive: %s", h.Name)
+		}
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(name, 0777); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// git runs git with args in dir and returns its output.
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.Output()
+	var ee *exec.ExitError
+	if errors.As(err, &ee) && len(ee.Stderr) != 0 {
+		err = fmt.Errorf("%v: %s", err, bytes.TrimSpace(ee.Stderr))
+	}
+	return string(out), err
+}
+
+// diffKey identifies a type across the sides: by its directory relative to
+// the root of its side, and its name without the "*" of a pointer-only
+// implementer.
+func diffKey(ri ResultIdentifier) string {
+	return filepath.Dir(ri.Pos.Filename) + " " + strings.TrimPrefix(ri.Name, "*")
+}
+
+// diffResults returns the changes from the Results of the base to those of
+// the head, for each interface whose implementers changed, ordered by
+// interface name.
+func diffResults(base, head []Result) []InterfaceDiff {
+	type sides struct {
+		iface      ResultIdentifier
+		base, head map[string]ResultIdentifier
+	}
+	byIface := make(map[string]*sides)
+	add := func(res []Result, isHead bool) {
+		for _, r := range res {
+			k := diffKey(r.Interface)
+			s, ok := byIface[k]
+			if !ok {
+				s = &sides{base: make(map[string]ResultIdentifier), head: make(map[string]ResultIdentifier)}
+				byIface[k] = s
+			}
+			m := s.base
+			if isHead {
+				m = s.head
+			}
+			if isHead || !ok {
+				s.iface = r.Interface
+			}
+			for _, im := range r.Implementers {
+				m[diffKey(im)] = im
+			}
+		}
+	}
+	add(base, false)
+	add(head, true)
+
+	var diffs []InterfaceDiff
+	for _, s := range byIface {
+		d := InterfaceDiff{Interface: s.iface}
+		for k, im := range s.head {
+			b, ok := s.base[k]
+			switch {
+			case !ok:
+				d.Added = append(d.Added, im)
+			case (b.Receiver == receiverPointer) != (im.Receiver == receiverPointer):
+				d.Changed = append(d.Changed, ReceiverChange{im, b.Receiver})
+			}
+		}
+		for k, im := range s.base {
+			if _, ok := s.head[k]; !ok {
+				d.Removed = append(d.Removed, im)
+			}
+		}
+		if len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 {
+			continue
+		}
+		byName := func(s []ResultIdentifier) {
+			sort.Slice(s, func(i, j int) bool { return less(s[i].sortItem(), s[j].sortItem(), "name") })
+		}
+		byName(d.Added)
+		byName(d.Removed)
+		sort.Slice(d.Changed, func(i, j int) bool {
+			return less(d.Changed[i].sortItem(), d.Changed[j].sortItem(), "name")
+		})
+		diffs = append(diffs, d)
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return less(diffs[i].Interface.sortItem(), diffs[j].Interface.sortItem(), "name")
+	})
+	return diffs
+}
+
+// outputDiff writes diffs to w in the specified format.
+func outputDiff(w io.Writer, diffs []InterfaceDiff, format string) {
+	switch format {
+	case "plain":
+		if len(diffs) == 0 {
+			fmt.Fprintln(w, "No changes.")
+		}
+		for i, d := range diffs {
+			fmt.Fprintf(w, "%s:\n", d.Interface.Name)
+			for _, im := range d.Added {
+				fmt.Fprintf(w, "  + %s: %s\n", im.Pos, im.Name)
+			}
+			for _, im := range d.Removed {
+				fmt.Fprintf(w, "  - %s: %s\n", im.Pos, im.Name)
+			}
+			for _, c := range d.Changed {
+				fmt.Fprintf(w, "  ~ %s: %s (receiver %s -> %s)\n", c.Pos, c.Name, c.BaseReceiver, c.Receiver)
+			}
+			if i != len(diffs)-1 {
+				fmt.Fprintln(w)
+			}
+		}
+	case "json":
+		if diffs == nil {
+			diffs = make([]InterfaceDiff, 0)
+		}
+		b, err := json.MarshalIndent(diffs, "", "  ")
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Fprintf(w, "%s\n", b)
+	case "xml":
+		b, err := xml.MarshalIndent(diffs, "", "  ")
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Fprintf(w, "%s\n", b)
+	}
+}
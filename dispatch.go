@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+	"strings"
+)
+
+const dispatchUsage = `List every dynamic call site of an interface method, and the concrete
+methods each call site may dispatch to.
+
+A call site is a call, method value, or method expression of a method on an
+interface type. It is listed if it may dispatch to the method of a concrete
+implementer of the specified interface. The possible callees are computed
+with class hierarchy analysis: every concrete type in the path that
+implements the static type of the receiver.
+
+Example:
+  impl dispatch -interface testpkg.Foo -method Baz -path ./internal/testdata
+
+Flags:`
+
+// CallSite is a dynamic call of an interface method.
+type CallSite struct {
+	Pos token.Position
+	End token.Position
+	// Method is the statically called method, for example testpkg.Foo.Baz.
+	Method string
+	// Callees are the concrete methods the call may dispatch to.
+	Callees []ResultIdentifier
+}
+
+func dispatchMain(ctx context.Context, args []string) {
+	var method string
+	fs := flag.NewFlagSet("dispatch", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, dispatchUsage)
+		fs.PrintDefaults()
+	}
+	addPathFlags(fs)
+	fs.StringVar(&arg.Interface, "interface", "", "interface declaring the method, format: packageName.interfaceName")
+	fs.StringVar(&method, "method", "", "name of the interface method to find call sites for")
+	fs.StringVar(&arg.Format, "format", "plain", "output format, should be one of: {plain,json,xml,quickfix}")
+	addPathsFlag(fs)
+	addDiagnosticsFlag(fs)
+	addLoadFlags(fs)
+	parseFlags(fs, "impl dispatch", args)
+
+	if err := applyConfig(fs, "impl dispatch"); err != nil {
+		fatal(err)
+	}
+	if err := checkDispatchFlags(method); err != nil {
+		fatal(err)
+	}
+
+	cfg := argLoadConfig()
+	cfg.FuncBodies = true
+	objects, pkgs, err := loadPaths(ctx, arg.Path, cfg)
+	if err != nil {
+		fatal(err)
+	}
+	sites, err := findCallSites(objects, pkgs, arg.Interface, method)
+	if err != nil {
+		fatal(err)
+	}
+	outputCallSites(sites, arg.Format)
+	if len(sites) == 0 {
+		os.Exit(exitNoneFound)
+	}
+}
+
+func checkDispatchFlags(method string) error {
+	const cmd = "impl dispatch"
+	if err := resolveScope(cmd); err != nil {
+		return err
+	}
+	switch {
+	case len(arg.Path) == 0 && !arg.Std:
+		return usageErrorf(cmd, "must specify directory to search (-path flag) or -std.")
+	case len(strings.Split(arg.Interface, ".")) != 2:
+		return usageErrorf(cmd, "must specify interface name in format: packageName.interfaceName (-interface flag).")
+	case method == "":
+		return usageErrorf(cmd, "must specify method name (-method flag).")
+	case !contains([]string{"plain", "json", "xml", "quickfix"}, arg.Format):
+		return usageErrorf(cmd, "output format should be one of: {plain,json,xml,quickfix}")
+	}
+	if err := checkPathsFlag(cmd); err != nil {
+		return err
+	}
+	return checkDiagnosticsFlag(cmd)
+}
+
+// findCallSites returns the call sites in pkgs that may dispatch to the
+// method named method of a concrete implementer of targetInterface. The
+// packages must have been loaded with loadConfig.FuncBodies.
+func findCallSites(objects []ObjectIdent, pkgs []*Package, targetInterface, method string) ([]CallSite, error) {
+	interfaces := filterInterfaces(objects, targetInterface)
+	if len(interfaces) == 0 {
+		return nil, &InterfaceNotFoundError{Name: targetInterface}
+	}
+
+	cha := newCHA(objects)
+	// The concrete methods that implement the target method, of every
+	// interface with the name, as packages in different directories may
+	// share a package name.
+	targets := make(map[*types.Func]bool)
+	found := false
+	for _, iface := range interfaces {
+		m, _, _ := types.LookupFieldOrMethod(iface.Type(), false, iface.Pkg(), method)
+		fn, ok := m.(*types.Func)
+		if !ok {
+			continue
+		}
+		found = true
+		for _, c := range cha.callees(iface.Type(), fn) {
+			targets[c.fn] = true
+		}
+	}
+	if !found {
+		return nil, &InterfaceNotFoundError{Name: targetInterface, Method: method}
+	}
+
+	var sites []CallSite
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			ast.Inspect(f, func(n ast.Node) bool {
+				sel, ok := n.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				s, ok := pkg.Info.Selections[sel]
+				if !ok || s.Kind() == types.FieldVal {
+					return true
+				}
+				fn := s.Obj().(*types.Func)
+				// A method promoted through an embedded interface field
+				// dispatches through the interface that declares it.
+				recv := s.Recv()
+				if !types.IsInterface(recv) {
+					recv = interfaceRecv(fn)
+				}
+				if recv == nil {
+					return true
+				}
+				callees := cha.callees(recv, fn)
+				if !dispatchesTo(callees, targets) {
+					return true
+				}
+				site := CallSite{
+					Pos:    pkg.Fset.Position(sel.Sel.Pos()),
+					End:    pkg.Fset.Position(sel.Sel.End()),
+					Method: types.TypeString(recv, nil) + "." + fn.Name(),
+				}
+				for _, c := range callees {
+					site.Callees = append(site.Callees, c.ResultIdentifier)
+				}
+				sites = append(sites, site)
+				return true
+			})
+		}
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		return comparePos(sites[i].Pos, sites[j].Pos) < 0
+	})
+	return sites, nil
+}
+
+// interfaceRecv returns the interface type that declares the method fn, or
+// nil if fn is a concrete method.
+func interfaceRecv(fn *types.Func) types.Type {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil || !types.IsInterface(recv.Type()) {
+		return nil
+	}
+	return recv.Type()
+}
+
+// dispatchesTo returns whether any of callees is in targets.
+func dispatchesTo(callees []callee, targets map[*types.Func]bool) bool {
+	for _, c := range callees {
+		if targets[c.fn] {
+			return true
+		}
+	}
+	return false
+}
+
+// cha performs class hierarchy analysis over the concrete named types in a
+// set of objects.
+type cha struct {
+	concrete []ObjectIdent
+	cache    map[chaKey][]callee
+}
+
+type chaKey struct {
+	recv types.Type
+	fn   *types.Func
+}
+
+// callee is a concrete method that an interface method call may dispatch to.
+type callee struct {
+	fn *types.Func
+	ResultIdentifier
+}
+
+func newCHA(objects []ObjectIdent) *cha {
+	c := &cha{cache: make(map[chaKey][]callee)}
+	seen := make(CharSet)
+	for _, o := range objects {
+		if _, ok := o.Object.(*types.TypeName); !ok || types.IsInterface(o.Type()) {
+			continue
+		}
+		if ch := NewChar(o); !seen[ch] {
+			seen[ch] = true
+			c.concrete = append(c.concrete, o)
+		}
+	}
+	return c
+}
+
+// callees returns the concrete methods that a call of the method fn on a
+// value of the interface type recv may dispatch to.
+func (c *cha) callees(recv types.Type, fn *types.Func) []callee {
+	key := chaKey{recv, fn}
+	if cs, ok := c.cache[key]; ok {
+		return cs
+	}
+	it := recv.Underlying().(*types.Interface)
+	var cs []callee
+	for _, o := range c.concrete {
+		typ := o.Type()
+		if !types.Implements(typ, it) {
+			typ = types.NewPointer(typ)
+			if !types.Implements(typ, it) {
+				continue
+			}
+		}
+		sel := types.NewMethodSet(typ).Lookup(fn.Pkg(), fn.Name())
+		if sel == nil {
+			continue
+		}
+		m := sel.Obj().(*types.Func)
+		if interfaceRecv(m) != nil {
+			// The method of an embedded interface field, which
+			// dispatches to the other concrete types.
+			continue
+		}
+		cs = append(cs, callee{m, ResultIdentifier{
+			Name: fmt.Sprintf("(%s).%s", types.TypeString(typ, nil), m.Name()),
+			Pos:  o.FileSet.Position(m.Pos()),
+			End:  o.FileSet.Position(m.Pos() + token.Pos(len(m.Name()))),
+		}})
+	}
+	c.cache[key] = cs
+	return cs
+}
+
+// outputCallSites prints sites in the specified format.
+func outputCallSites(sites []CallSite, format string) {
+	style := pathStyle(format)
+	switch format {
+	case "plain":
+		if len(sites) == 0 {
+			fmt.Println("No call sites.")
+		}
+		for _, s := range sites {
+			fmt.Printf("%s: %s\n", displayPos(s.Pos, style), s.Method)
+			for _, c := range s.Callees {
+				fmt.Printf("\t-> %s: %s\n", displayPos(c.Pos, style), c.Name)
+			}
+		}
+	case "quickfix":
+		for _, s := range sites {
+			names := make([]string, len(s.Callees))
+			for i, c := range s.Callees {
+				names[i] = c.Name
+			}
+			writeQuickfix(os.Stdout, s.Pos, style, "%s may call %s", s.Method, strings.Join(names, ", "))
+		}
+	case "json":
+		b, err := json.MarshalIndent(sites, "", "  ")
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Printf("%s\n", b)
+	case "xml":
+		b, err := xml.MarshalIndent(sites, "", "  ")
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Printf("%s\n", b)
+	}
+}
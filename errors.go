@@ -1,6 +1,32 @@
 package main
 
-import "fmt"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"os"
+)
+
+// Exit codes, so that scripts can tell the outcomes apart.
+const (
+	exitFound             = 0 // At least one result was found.
+	exitNoneFound         = 1 // The query ran, but found nothing.
+	exitUsage             = 2 // The flags are invalid, as for the flag package.
+	exitInterfaceNotFound = 3 // The interface or type, or its method, is not in the path.
+	exitLoadError         = 4 // Loading the path failed, or any other error.
+	exitLintErrors        = 5 // impl lint found a problem with severity error.
+)
+
+// Errors that the typed errors below match with errors.Is.
+var (
+	ErrUsage             = errors.New("invalid usage")
+	ErrLoad              = errors.New("failed to load")
+	ErrInterfaceNotFound = errors.New("interface not found")
+	ErrTypeNotFound      = errors.New("type not found")
+)
 
 type wrappedErr struct {
 	message string
@@ -11,13 +37,210 @@ func (w wrappedErr) Error() string {
 	return fmt.Sprintf("%s: %v", w.message, w.err)
 }
 
+func (w wrappedErr) Unwrap() error {
+	return w.err
+}
+
 func wrapErr(msg string, e error) error {
-	switch e.(type) {
-	case wrappedErr:
-		msg = msg + ": " + e.(wrappedErr).message
-	}
 	return wrappedErr{
 		message: msg,
 		err:     e,
 	}
 }
+
+// UsageError is an invalid flag or combination of flags.
+type UsageError struct {
+	Cmd     string // The command, for the help message.
+	Message string
+}
+
+func (e *UsageError) Error() string {
+	return fmt.Sprintf("%s\nRun '%s -h' for details.", e.Message, e.Cmd)
+}
+
+func (e *UsageError) Is(target error) bool {
+	return target == ErrUsage
+}
+
+// usageErrorf returns a *UsageError for cmd with the formatted message.
+func usageErrorf(cmd, format string, a ...interface{}) error {
+	return &UsageError{Cmd: cmd, Message: fmt.Sprintf(format, a...)}
+}
+
+// SourceError is an error at a position in a source file.
+type SourceError struct {
+	Pos     token.Position
+	Message string
+}
+
+// ParseError is a failure to parse a file.
+type ParseError struct {
+	Filename string
+	Errors   []SourceError
+	err      error
+}
+
+// newParseError returns a *ParseError for the error that parsing filename
+// returned.
+func newParseError(filename string, err error) *ParseError {
+	e := &ParseError{Filename: filename, err: err}
+	var list scanner.ErrorList
+	if errors.As(err, &list) {
+		for _, se := range list {
+			e.Errors = append(e.Errors, SourceError{se.Pos, se.Msg})
+		}
+	}
+	return e
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse file: %v", e.err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.err
+}
+
+func (e *ParseError) Is(target error) bool {
+	return target == ErrLoad
+}
+
+// TypeCheckError is a failure to type-check a package. Errors holds every
+// error found, not only the first.
+type TypeCheckError struct {
+	Dir    string
+	Errors []SourceError
+	err    error // The first error.
+}
+
+// newTypeCheckError returns a *TypeCheckError for the package in dir from
+// the errors that type-checking it reported.
+func newTypeCheckError(dir string, errs []error) *TypeCheckError {
+	e := &TypeCheckError{Dir: dir, err: errs[0]}
+	for _, err := range errs {
+		var te types.Error
+		if errors.As(err, &te) {
+			e.Errors = append(e.Errors, SourceError{te.Fset.Position(te.Pos), te.Msg})
+		} else {
+			e.Errors = append(e.Errors, SourceError{Message: err.Error()})
+		}
+	}
+	return e
+}
+
+func (e *TypeCheckError) Error() string {
+	msg := fmt.Sprintf("type-checks failed. Make sure dependencies are completely installed: %v", e.err)
+	if len(e.Errors) > 1 {
+		msg += fmt.Sprintf(" (and %d more errors)", len(e.Errors)-1)
+	}
+	return msg
+}
+
+func (e *TypeCheckError) Unwrap() error {
+	return e.err
+}
+
+func (e *TypeCheckError) Is(target error) bool {
+	return target == ErrLoad
+}
+
+// InterfaceNotFoundError is an interface that is not declared in the
+// searched path, or that has no method named Method if Method is not "".
+type InterfaceNotFoundError struct {
+	Name   string
+	Method string
+}
+
+func (e *InterfaceNotFoundError) Error() string {
+	if e.Method != "" {
+		return fmt.Sprintf("interface %s has no method %s", e.Name, e.Method)
+	}
+	return fmt.Sprintf("interface %s not found", e.Name)
+}
+
+func (e *InterfaceNotFoundError) Is(target error) bool {
+	return target == ErrInterfaceNotFound
+}
+
+// TypeNotFoundError is a named type that is not declared in the searched
+// path, or that has no exported method named Method if Method is not "".
+type TypeNotFoundError struct {
+	Name   string
+	Method string
+}
+
+func (e *TypeNotFoundError) Error() string {
+	if e.Method != "" {
+		return fmt.Sprintf("type %s has no exported method %s", e.Name, e.Method)
+	}
+	return fmt.Sprintf("type %s not found", e.Name)
+}
+
+func (e *TypeNotFoundError) Is(target error) bool {
+	return target == ErrTypeNotFound
+}
+
+// exitCode returns the exit code for err.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, ErrUsage):
+		return exitUsage
+	case errors.Is(err, ErrInterfaceNotFound), errors.Is(err, ErrTypeNotFound):
+		return exitInterfaceNotFound
+	default:
+		return exitLoadError
+	}
+}
+
+// diagnosticsFormats are the supported values of the -diagnostics flag.
+var diagnosticsFormats = []string{"plain", "json"}
+
+// Diagnostic is an error as written by -diagnostics json.
+type Diagnostic struct {
+	// Kind is "usage", "parse", "type-check", "interface-not-found",
+	// "type-not-found" or "error".
+	Kind     string
+	Message  string
+	Errors   []SourceError `json:",omitempty"`
+	ExitCode int
+}
+
+// newDiagnostic returns the Diagnostic for err.
+func newDiagnostic(err error) Diagnostic {
+	d := Diagnostic{Kind: "error", Message: err.Error(), ExitCode: exitCode(err)}
+	var (
+		usage    *UsageError
+		parse    *ParseError
+		check    *TypeCheckError
+		notFound *InterfaceNotFoundError
+		typeNF   *TypeNotFoundError
+	)
+	switch {
+	case errors.As(err, &usage):
+		d.Kind, d.Message = "usage", usage.Message
+	case errors.As(err, &parse):
+		d.Kind, d.Errors = "parse", parse.Errors
+	case errors.As(err, &check):
+		d.Kind, d.Errors = "type-check", check.Errors
+	case errors.As(err, &notFound):
+		d.Kind = "interface-not-found"
+	case errors.As(err, &typeNF):
+		d.Kind = "type-not-found"
+	}
+	return d
+}
+
+// fatal reports err on stderr in the format of the -diagnostics flag and
+// exits with the exit code for err.
+func fatal(err error) {
+	if arg.Diagnostics == "json" {
+		b, merr := json.MarshalIndent(newDiagnostic(err), "", "  ")
+		if merr != nil {
+			logger.Fatal(merr)
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", b)
+	} else {
+		logger.Print(err)
+	}
+	os.Exit(exitCode(err))
+}
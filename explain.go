@@ -0,0 +1,73 @@
+package main
+
+import (
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// MethodMatch is a method of an interface and the method of an implementer
+// that provides it.
+type MethodMatch struct {
+	Method string // The interface method.
+	// Provider is the implementer's method, such as "(*p.T).Read", or ""
+	// if the implementer lacks it.
+	Provider string
+	Pos      token.Position
+	// Pointer is whether the method has a pointer receiver, so that only
+	// *T provides it.
+	Pointer bool
+	// Promoted is the path of embedded fields the method is promoted
+	// through, such as "Base.Inner", or "" if it is declared on the type.
+	Promoted string
+}
+
+// explain returns how obj provides each method of the interface type iface,
+// in the order of the interface's methods.
+func explain(obj ObjectIdent, iface types.Type) []MethodMatch {
+	it := iface.Underlying().(*types.Interface)
+	typ := obj.Type()
+	vset := types.NewMethodSet(typ)
+	pset := vset
+	if !types.IsInterface(typ) {
+		pset = types.NewMethodSet(types.NewPointer(typ))
+	}
+
+	var matches []MethodMatch
+	for i := 0; i < it.NumMethods(); i++ {
+		m := it.Method(i)
+		mm := MethodMatch{Method: m.Name()}
+		if sel := pset.Lookup(m.Pkg(), m.Name()); sel != nil {
+			fn := sel.Obj().(*types.Func)
+			recv := types.TypeString(typ, nil)
+			mm.Pointer = vset.Lookup(m.Pkg(), m.Name()) == nil
+			if mm.Pointer {
+				recv = "*" + recv
+			}
+			mm.Provider = "(" + recv + ")." + fn.Name()
+			mm.Pos = obj.FileSet.Position(fn.Pos())
+			mm.Promoted = promotedPath(typ, sel.Index())
+		}
+		matches = append(matches, mm)
+	}
+	return matches
+}
+
+// promotedPath returns the names of the embedded fields along index, the
+// path of a method selection on typ, or "" if the method is not promoted.
+func promotedPath(typ types.Type, index []int) string {
+	var names []string
+	for _, i := range index[:len(index)-1] {
+		if p, ok := typ.Underlying().(*types.Pointer); ok {
+			typ = p.Elem()
+		}
+		st, ok := typ.Underlying().(*types.Struct)
+		if !ok {
+			break
+		}
+		f := st.Field(i)
+		names = append(names, f.Name())
+		typ = f.Type()
+	}
+	return strings.Join(names, ".")
+}
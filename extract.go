@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const extractUsage = `Print an interface declaration with the methods of a type.
+
+Usage:
+  impl extract -type <[*]pkg.Name> -name <Name> -path <path> [flags]
+
+The interface has the exported methods of the type, or of the pointer to it
+if the type starts with "*", with their doc comments. -methods limits it to
+the listed methods, and -used-by to the methods that the packages in a path
+call on the type. Types of the type's own package are not qualified, so that
+the declaration can be added to that package; types of other packages are
+qualified by package name, and the declaration starts with their imports.
+
+Example:
+  impl extract -type '*db.Client' -name Querier -path ./db -used-by ./...
+
+Flags:`
+
+func extractMain(ctx context.Context, args []string) {
+	var (
+		typeName, name, usedBy string
+		methods                stringsFlag
+	)
+	fs := flag.NewFlagSet("extract", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, extractUsage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&typeName, "type", "", "type to extract the interface from, format: packageName.TypeName, or *packageName.TypeName for the methods of the pointer")
+	fs.StringVar(&name, "name", "", "name of the interface")
+	addPathFlags(fs)
+	fs.Var(&methods, "methods", "comma-separated methods to include; by default all the exported methods are")
+	fs.StringVar(&usedBy, "used-by", "", "path whose calls on the type choose the methods to include; a directory ending in /... is searched recursively")
+	addDiagnosticsFlag(fs)
+	addLoadFlags(fs)
+	parseFlags(fs, "impl extract", args)
+
+	if err := applyConfig(fs, "impl extract"); err != nil {
+		fatal(err)
+	}
+	if err := checkExtractFlags(typeName, name); err != nil {
+		fatal(err)
+	}
+
+	cfg := argLoadConfig()
+	cfg.Comments = true // For the doc comments of the methods.
+	objects, pkgs, err := loadPaths(ctx, arg.Path, cfg)
+	if err != nil {
+		fatal(err)
+	}
+	typ, ptr, err := findType(objects, typeName)
+	if err != nil {
+		fatal(err)
+	}
+	ms, err := typeMethods(typ, ptr, methods)
+	if err != nil {
+		fatal(err)
+	}
+	if usedBy != "" {
+		cfg := argLoadConfig()
+		cfg.FuncBodies = true
+		_, users, err := loadPath(ctx, usedBy, cfg)
+		if err != nil {
+			fatal(err)
+		}
+		ms = calledMethods(ms, typ, packagePath(importPaths(pkgs), typ.Pkg()), users)
+		if len(ms) == 0 {
+			logger.Printf("no methods of %s are called in %s", typeName, usedBy)
+			os.Exit(exitNoneFound)
+		}
+	}
+	src, err := extractInterface(typ, ptr, name, ms, methodDocs(pkgs), importPaths(pkgs))
+	if err != nil {
+		fatal(err)
+	}
+	os.Stdout.Write(src)
+}
+
+func checkExtractFlags(typeName, name string) error {
+	const cmd = "impl extract"
+	if err := resolveScope(cmd); err != nil {
+		return err
+	}
+	switch {
+	case len(arg.Path) == 0 && !arg.Std:
+		return usageErrorf(cmd, "must specify directory to search (-path flag) or -std.")
+	case len(strings.Split(strings.TrimPrefix(typeName, "*"), ".")) != 2:
+		return usageErrorf(cmd, "must specify type name in format: packageName.TypeName (-type flag).")
+	case !token.IsIdentifier(name):
+		return usageErrorf(cmd, "must specify the name of the interface, an identifier (-name flag).")
+	}
+	return checkDiagnosticsFlag(cmd)
+}
+
+// findType returns the declaration of the named concrete type name, and
+// whether name starts with "*" for the pointer to the type.
+func findType(objects []ObjectIdent, name string) (ObjectIdent, bool, error) {
+	ptr := strings.HasPrefix(name, "*")
+	name = strings.TrimPrefix(name, "*")
+	for _, o := range objects {
+		if _, ok := o.Object.(*types.TypeName); !ok || types.IsInterface(o.Type()) {
+			continue
+		}
+		if types.TypeString(o.Type(), nil) == name {
+			if named, ok := o.Type().(*types.Named); ok && named.TypeParams().Len() != 0 {
+				return ObjectIdent{}, false, fmt.Errorf("type %s is generic, which is not supported", name)
+			}
+			return o, ptr, nil
+		}
+	}
+	return ObjectIdent{}, false, &TypeNotFoundError{Name: name}
+}
+
+// methodSetOf returns the method set of the type declared by o, or of the
+// pointer to it if ptr is true.
+func methodSetOf(o ObjectIdent, ptr bool) *types.MethodSet {
+	if ptr {
+		return types.NewMethodSet(types.NewPointer(o.Type()))
+	}
+	return types.NewMethodSet(o.Type())
+}
+
+// typeMethods returns the exported methods in the method set of typ, or of
+// the pointer to it if ptr is true, ordered by name. If names is not empty,
+// only the methods named in it are returned, and each must exist.
+func typeMethods(typ ObjectIdent, ptr bool, names []string) ([]*types.Func, error) {
+	mset := methodSetOf(typ, ptr)
+	var ms []*types.Func
+	for i := 0; i < mset.Len(); i++ {
+		m := mset.At(i).Obj().(*types.Func)
+		if m.Exported() && (len(names) == 0 || contains(names, m.Name())) {
+			ms = append(ms, m)
+		}
+	}
+	for _, n := range names {
+		if sel := mset.Lookup(nil, n); sel == nil || !sel.Obj().Exported() {
+			return nil, &TypeNotFoundError{Name: types.TypeString(typ.Type(), nil), Method: n}
+		}
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Name() < ms[j].Name() })
+	return ms, nil
+}
+
+// calledMethods returns the methods in ms that the packages in users call,
+// or take the value of, on typ or a pointer to it. The packages must have
+// been loaded with loadConfig.FuncBodies. Since users are loaded
+// separately, typ is matched by the import path of its package, typPath,
+// and its name.
+func calledMethods(ms []*types.Func, typ ObjectIdent, typPath string, users []*Package) []*types.Func {
+	paths := importPaths(users)
+	called := make(map[string]bool)
+	for _, pkg := range users {
+		for _, sel := range pkg.Info.Selections {
+			if sel.Kind() == types.FieldVal {
+				continue
+			}
+			recv := sel.Recv()
+			if p, ok := recv.(*types.Pointer); ok {
+				recv = p.Elem()
+			}
+			named, ok := recv.(*types.Named)
+			if !ok || named.Obj().Name() != typ.Name() || named.Obj().Pkg() == nil {
+				continue
+			}
+			if p := named.Obj().Pkg(); p.Name() != typ.Pkg().Name() || packagePath(paths, p) != typPath {
+				continue
+			}
+			called[sel.Obj().Name()] = true
+		}
+	}
+	var used []*types.Func
+	for _, m := range ms {
+		if called[m.Name()] {
+			used = append(used, m)
+		}
+	}
+	return used
+}
+
+// importPaths returns the import paths of the type-checked packages of pkgs,
+// and of the loaded packages they import. A package outside a module and
+// GOPATH is identified by its directory instead.
+func importPaths(pkgs []*Package) map[*types.Package]string {
+	paths := make(map[*types.Package]string)
+	var add func(pkg *Package)
+	add = func(pkg *Package) {
+		if _, ok := paths[pkg.Types]; ok {
+			return
+		}
+		path := pkg.ImportPath
+		if path == "" {
+			path, _ = filepath.Abs(pkg.Dir)
+		}
+		paths[pkg.Types] = path
+		for _, dep := range pkg.deps {
+			add(dep)
+		}
+	}
+	for _, pkg := range pkgs {
+		add(pkg)
+	}
+	return paths
+}
+
+// packagePath returns the import path of p in paths, or the path of p
+// itself, which is its import path if it was not loaded, such as a package
+// of the standard library.
+func packagePath(paths map[*types.Package]string, p *types.Package) string {
+	if path, ok := paths[p]; ok {
+		return path
+	}
+	return p.Path()
+}
+
+// methodDocs returns the doc comments of the methods declared in pkgs. The
+// packages must have been loaded with loadConfig.Comments.
+func methodDocs(pkgs []*Package) map[types.Object]*ast.CommentGroup {
+	docs := make(map[types.Object]*ast.CommentGroup)
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			for _, decl := range f.Decls {
+				fd, ok := decl.(*ast.FuncDecl)
+				if !ok || fd.Recv == nil || fd.Doc == nil {
+					continue
+				}
+				if obj := pkg.Info.Defs[fd.Name]; obj != nil {
+					docs[obj] = fd.Doc
+				}
+			}
+		}
+	}
+	return docs
+}
+
+// extractInterface returns the formatted declaration of the interface name
+// with the methods ms of typ, or of the pointer to it if ptr is true, after
+// confirming that the type implements it. paths has the import paths of the
+// loaded packages, as returned by importPaths.
+func extractInterface(typ ObjectIdent, ptr bool, name string, ms []*types.Func, docs map[types.Object]*ast.CommentGroup, paths map[*types.Package]string) ([]byte, error) {
+	iface := newInterfaceObject(typ.Pkg(), name, ms)
+	if _, ok := implementation(typ, iface); !ok {
+		return nil, fmt.Errorf("%s does not implement the extracted interface %s", types.TypeString(typ.Type(), nil), name)
+	}
+	recv := types.TypeString(typ.Type(), nil)
+	if ptr {
+		recv = "*" + recv
+	}
+	doc := fmt.Sprintf("%s is extracted from %s, which implements it.", name, recv)
+	return interfaceDecl(name, doc, ms, docs, newImportQualifier(typ.Pkg(), paths))
+}
+
+// newInterfaceObject returns an ObjectIdent for a new interface named name in
+// pkg with the methods ms, which may have receivers.
+func newInterfaceObject(pkg *types.Package, name string, ms []*types.Func) ObjectIdent {
+	var methods []*types.Func
+	for _, m := range ms {
+		sig := m.Type().(*types.Signature)
+		methods = append(methods, types.NewFunc(token.NoPos, m.Pkg(), m.Name(), types.NewSignatureType(nil, nil, nil, sig.Params(), sig.Results(), sig.Variadic())))
+	}
+	it := types.NewInterfaceType(methods, nil).Complete()
+	tn := types.NewTypeName(token.NoPos, pkg, name, nil)
+	types.NewNamed(tn, it, nil)
+	return ObjectIdent{Object: tn, FileSet: token.NewFileSet()}
+}
+
+// interfaceDecl returns the formatted declaration of the interface name with
+// the doc comment doc and the methods ms, each with its doc comment in docs,
+// preceded by the imports of the packages that q qualifies.
+func interfaceDecl(name, doc string, ms []*types.Func, docs map[types.Object]*ast.CommentGroup, q *importQualifier) ([]byte, error) {
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "// %s\ntype %s interface {\n", doc, name)
+	for _, m := range ms {
+		if cg := docs[m]; cg != nil {
+			for _, c := range cg.List {
+				fmt.Fprintf(&body, "%s\n", c.Text)
+			}
+		}
+		body.WriteString(m.Name())
+		types.WriteSignature(&body, m.Type().(*types.Signature), q.qualify)
+		body.WriteString("\n")
+	}
+	body.WriteString("}\n")
+
+	var buf bytes.Buffer
+	if len(q.imports) != 0 {
+		sort.Slice(q.imports, func(i, j int) bool { return q.imports[i].path < q.imports[j].path })
+		buf.WriteString("import (\n")
+		for _, imp := range q.imports {
+			if imp.name != imp.pkg.Name() {
+				buf.WriteString(imp.name + " ")
+			}
+			fmt.Fprintf(&buf, "%q\n", imp.path)
+		}
+		buf.WriteString(")\n\n")
+	}
+	buf.Write(body.Bytes())
+	return format.Source(buf.Bytes())
+}
+
+// importQualifier qualifies the types of packages other than local by
+// package name, and records the imports that the qualified names need. A
+// package whose name is taken by another package is imported with a
+// numbered name.
+type importQualifier struct {
+	local   *types.Package            // nil if every package is qualified.
+	paths   map[*types.Package]string // As returned by importPaths.
+	imports []qualifiedImport
+}
+
+type qualifiedImport struct {
+	pkg  *types.Package
+	path string
+	name string
+}
+
+func newImportQualifier(local *types.Package, paths map[*types.Package]string) *importQualifier {
+	return &importQualifier{local: local, paths: paths}
+}
+
+// qualify is a types.Qualifier.
+func (q *importQualifier) qualify(p *types.Package) string {
+	if p == q.local {
+		return ""
+	}
+	path := packagePath(q.paths, p)
+	for _, imp := range q.imports {
+		if imp.path == path {
+			return imp.name
+		}
+	}
+	name := p.Name()
+	for i := 2; q.taken(name); i++ {
+		name = fmt.Sprintf("%s%d", p.Name(), i)
+	}
+	q.imports = append(q.imports, qualifiedImport{pkg: p, path: path, name: name})
+	return name
+}
+
+// taken reports whether name is the name of the local package or of an
+// import.
+func (q *importQualifier) taken(name string) bool {
+	if q.local != nil && q.local.Name() == name {
+		return true
+	}
+	for _, imp := range q.imports {
+		if imp.name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,582 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+const fakeUsage = `Generate a fake implementation of an interface, for tests.
+
+Usage:
+  impl fake -interface <pkg.Name> -path <path> [-out <file>] [flags]
+
+The fake is a struct that is safe for concurrent use. For each method M of the
+interface, including the methods of embedded interfaces, it has:
+  MStub                  a func that, if set, is called instead
+  MCallCount()           the number of calls
+  MArgsForCall(i)        the arguments of the ith call
+  MCalls(stub)           sets MStub
+  MReturns(...)          sets the results of every call
+  MReturnsOnCall(i, ...) sets the results of the ith call
+and Invocations() returns the arguments of every call, by method name. So an
+interface with a method named Invocations cannot be faked.
+
+The fake of a generic interface has the same type parameters.
+
+Example:
+  impl fake -interface store.Repo -path ./store -out ./store/fakes/repo.go
+
+Flags:`
+
+func fakeMain(ctx context.Context, args []string) {
+	var out, pkgName, name string
+	fs := flag.NewFlagSet("fake", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, fakeUsage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&arg.Interface, "interface", "", "interface to fake, format: packageName.interfaceName")
+	addPathFlags(fs)
+	fs.StringVar(&out, "out", "", "file to write the fake to; by default it is written to stdout")
+	fs.StringVar(&pkgName, "package", "", "package name of the fake; by default the package in the directory of -out, or the directory's name")
+	fs.StringVar(&name, "name", "", "name of the fake type; by default Fake followed by the interface name")
+	addDiagnosticsFlag(fs)
+	addLoadFlags(fs)
+	parseFlags(fs, "impl fake", args)
+
+	if err := applyConfig(fs, "impl fake"); err != nil {
+		fatal(err)
+	}
+	if err := checkFakeFlags(); err != nil {
+		fatal(err)
+	}
+
+	objects, pkgs, err := loadPaths(ctx, arg.Path, argLoadConfig())
+	if err != nil {
+		fatal(err)
+	}
+	interfaces := filterInterfaces(objects, arg.Interface)
+	if len(interfaces) == 0 {
+		fatal(&InterfaceNotFoundError{Name: arg.Interface})
+	}
+	src, err := generateFake(interfaces[0], pkgs, fakeOptions{Out: out, Package: pkgName, Name: name})
+	if err != nil {
+		fatal(err)
+	}
+	if out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0777); err != nil {
+		fatal(err)
+	}
+	if err := os.WriteFile(out, src, 0666); err != nil {
+		fatal(err)
+	}
+}
+
+func checkFakeFlags() error {
+	const cmd = "impl fake"
+	if err := resolveScope(cmd); err != nil {
+		return err
+	}
+	switch {
+	case len(arg.Path) == 0 && !arg.Std:
+		return usageErrorf(cmd, "must specify directory to search (-path flag) or -std.")
+	case len(strings.Split(arg.Interface, ".")) != 2:
+		return usageErrorf(cmd, "must specify interface name in format: packageName.interfaceName (-interface flag).")
+	}
+	return checkDiagnosticsFlag(cmd)
+}
+
+// fakeOptions control the fake that generateFake generates.
+type fakeOptions struct {
+	// Out is the file the fake is written to, or "" for stdout. If its
+	// directory holds one of the loaded packages, the fake is in that
+	// package.
+	Out string
+	// Package is the package name of the fake. If "", it is the name of
+	// the package in Out's directory, or else the directory's name, or
+	// "fakes" for stdout.
+	Package string
+	// Name is the name of the fake type. If "", it is "Fake" followed by
+	// the interface name.
+	Name string
+}
+
+// generateFake returns the formatted source of a fake of iface, which is
+// declared in one of pkgs.
+func generateFake(iface ObjectIdent, pkgs []*Package, opts fakeOptions) ([]byte, error) {
+	it := iface.Type().Underlying().(*types.Interface)
+	if !it.IsMethodSet() {
+		return nil, fmt.Errorf("%s is a constraint, which cannot be implemented", iface.Name())
+	}
+
+	im := newFakeImports(pkgs, opts.Out)
+	d := fakeData{
+		Package: opts.Package,
+		Name:    opts.Name,
+		Of:      iface.Pkg().Name() + "." + iface.Name(),
+	}
+	if d.Name == "" {
+		d.Name = "Fake" + iface.Name()
+	}
+	if d.Package == "" {
+		d.Package = im.defaultPackage()
+	}
+
+	if named, ok := iface.Type().(*types.Named); ok && named.TypeParams().Len() != 0 {
+		var params, args []string
+		for i := 0; i < named.TypeParams().Len(); i++ {
+			tp := named.TypeParams().At(i)
+			params = append(params, tp.Obj().Name()+" "+types.TypeString(tp.Constraint(), im.qualifier))
+			args = append(args, tp.Obj().Name())
+		}
+		d.TypeParams = "[" + strings.Join(params, ", ") + "]"
+		d.TypeArgs = "[" + strings.Join(args, ", ") + "]"
+	} else {
+		// The fake of a generic interface cannot be checked without type
+		// arguments.
+		d.Interface = types.TypeString(iface.Type(), im.qualifier)
+	}
+
+	for i := 0; i < it.NumMethods(); i++ {
+		m := it.Method(i)
+		if !m.Exported() && !im.samePackage(m.Pkg()) {
+			return nil, fmt.Errorf("%s has the unexported method %s, so its fake must be in package %s", iface.Name(), m.Name(), m.Pkg().Name())
+		}
+		if fakeOwnNames[m.Name()] {
+			return nil, fmt.Errorf("%s has the method %s, which would collide with the fake's own %s", iface.Name(), m.Name(), m.Name())
+		}
+		d.Methods = append(d.Methods, newFakeMethod(m, im.qualifier))
+	}
+	if im.err != nil {
+		return nil, im.err
+	}
+	d.Imports = append(im.imports, fakeImport{Path: "sync"})
+	sort.Slice(d.Imports, func(i, j int) bool { return d.Imports[i].Path < d.Imports[j].Path })
+
+	var buf bytes.Buffer
+	if err := fakeTemplate.Execute(&buf, d); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid code for %s: %v", iface.Name(), err)
+	}
+	return src, nil
+}
+
+// fakeImports qualifies the types in the fake, and collects the imports
+// that they need.
+type fakeImports struct {
+	outDir  string                    // The absolute directory of the fake, or "" for stdout.
+	dirs    map[*types.Package]string // The absolute directories of the loaded packages.
+	paths   map[*types.Package]string // The import paths of the loaded packages.
+	outPkg  *types.Package            // The loaded package in outDir, if any.
+	imports []fakeImport
+	names   map[string]string // Import path to the name it is imported by.
+	taken   map[string]bool   // The names of the imports.
+	err     error             // The first package that cannot be imported.
+}
+
+// fakeReserved are the names used by the fake, which imports are renamed
+// not to shadow or be shadowed by.
+var fakeReserved = []string{"sync", "fake", "ret", "specificReturn", "stub", "fakeReturns", "argsForCall", "copied", "name", "calls", "args", "i"}
+
+type fakeImport struct {
+	Name string // The name the package is imported by, if not its own.
+	Path string
+}
+
+func newFakeImports(pkgs []*Package, out string) *fakeImports {
+	im := &fakeImports{
+		dirs:  make(map[*types.Package]string),
+		paths: make(map[*types.Package]string),
+		names: make(map[string]string),
+		taken: make(map[string]bool),
+	}
+	for _, name := range fakeReserved {
+		im.taken[name] = true
+	}
+	if out != "" {
+		im.outDir, _ = filepath.Abs(filepath.Dir(out))
+	}
+	for _, pkg := range pkgs {
+		dir, _ := filepath.Abs(pkg.Dir)
+		im.dirs[pkg.Types] = dir
+		im.paths[pkg.Types] = pkg.ImportPath
+		if im.outDir != "" && dir == im.outDir {
+			im.outPkg = pkg.Types
+		}
+	}
+	return im
+}
+
+// samePackage reports whether p is the package the fake is in.
+func (im *fakeImports) samePackage(p *types.Package) bool {
+	return p != nil && p == im.outPkg
+}
+
+// defaultPackage returns the package name of the fake if -package is not
+// set.
+func (im *fakeImports) defaultPackage() string {
+	switch {
+	case im.outPkg != nil:
+		return im.outPkg.Name()
+	case im.outDir == "":
+		return "fakes"
+	}
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return '_'
+	}, filepath.Base(im.outDir))
+	if r, _ := utf8.DecodeRuneInString(name); !unicode.IsLetter(r) {
+		name = "fakes"
+	}
+	return name
+}
+
+// qualifier is a types.Qualifier that names the packages other than the
+// fake's by the name they are imported by, and adds their imports.
+func (im *fakeImports) qualifier(p *types.Package) string {
+	if im.samePackage(p) {
+		return ""
+	}
+	path, ok := im.paths[p]
+	if !ok {
+		// Not loaded from the path, so imported by its path.
+		path = p.Path()
+	}
+	if path == "" {
+		if im.err == nil {
+			im.err = fmt.Errorf("cannot find the import path of package %s in %s; it is not in a module or GOPATH", p.Name(), im.dirs[p])
+		}
+		return p.Name()
+	}
+	if name, ok := im.names[path]; ok {
+		return name
+	}
+	name := p.Name()
+	for i := 2; im.taken[name]; i++ {
+		name = fmt.Sprintf("%s%d", p.Name(), i)
+	}
+	im.taken[name] = true
+	im.names[path] = name
+	imp := fakeImport{Path: path}
+	if name != p.Name() {
+		imp.Name = name
+	}
+	im.imports = append(im.imports, imp)
+	return name
+}
+
+// fakeData is the input of fakeTemplate.
+type fakeData struct {
+	Package    string
+	Imports    []fakeImport
+	Name       string
+	Of         string // The name of the interface, for the doc comment.
+	TypeParams string // The type parameter list of a generic fake.
+	TypeArgs   string // The type parameters as arguments, for the receivers.
+	Interface  string // The interface, if the fake can be checked to implement it.
+	Methods    []fakeMethod
+}
+
+// fakeOwnNames are the names that a fake declares for itself, as methods or
+// as the prefix of its fields, so that an interface with a method of the name
+// cannot be faked.
+var fakeOwnNames = map[string]bool{
+	"Invocations":      true,
+	"invocations":      true,
+	"recordInvocation": true,
+}
+
+// fakeMethod is a method of a fake.
+type fakeMethod struct {
+	Name    string
+	Field   string // The prefix of the unexported fields of the method.
+	Params  []fakeVar
+	Results []fakeVar
+	// Variadic is true if the last parameter is variadic. Its Type is a
+	// slice.
+	Variadic bool
+}
+
+// fakeVar is a parameter or result of a fakeMethod.
+type fakeVar struct {
+	Name string
+	Type string
+}
+
+func newFakeMethod(m *types.Func, q types.Qualifier) fakeMethod {
+	sig := m.Type().(*types.Signature)
+	fm := fakeMethod{
+		Name:     m.Name(),
+		Field:    lowerFirst(m.Name()),
+		Variadic: sig.Variadic(),
+	}
+	for i := 0; i < sig.Params().Len(); i++ {
+		fm.Params = append(fm.Params, fakeVar{fmt.Sprintf("arg%d", i+1), types.TypeString(sig.Params().At(i).Type(), q)})
+	}
+	for i := 0; i < sig.Results().Len(); i++ {
+		fm.Results = append(fm.Results, fakeVar{fmt.Sprintf("result%d", i+1), types.TypeString(sig.Results().At(i).Type(), q)})
+	}
+	return fm
+}
+
+// ParamList returns the parameter list of the method, with names.
+func (m fakeMethod) ParamList() string {
+	var s []string
+	for i, p := range m.Params {
+		typ := p.Type
+		if m.Variadic && i == len(m.Params)-1 {
+			typ = "..." + strings.TrimPrefix(typ, "[]")
+		}
+		s = append(s, p.Name+" "+typ)
+	}
+	return strings.Join(s, ", ")
+}
+
+// ResultList returns the result list of the method, with a leading space if
+// it is not empty.
+func (m fakeMethod) ResultList() string {
+	switch len(m.Results) {
+	case 0:
+		return ""
+	case 1:
+		return " " + m.Results[0].Type
+	}
+	return " (" + joinVars(m.Results, false, true) + ")"
+}
+
+// FuncType returns the type of the method's stub.
+func (m fakeMethod) FuncType() string {
+	var s []string
+	for i, p := range m.Params {
+		if m.Variadic && i == len(m.Params)-1 {
+			s = append(s, "..."+strings.TrimPrefix(p.Type, "[]"))
+			continue
+		}
+		s = append(s, p.Type)
+	}
+	return "func(" + strings.Join(s, ", ") + ")" + m.ResultList()
+}
+
+// CallArgs returns the arguments that call the stub with the parameters.
+func (m fakeMethod) CallArgs() string {
+	s := joinVars(m.Params, true, false)
+	if m.Variadic {
+		s += "..."
+	}
+	return s
+}
+
+// VariadicParam returns the variadic parameter of a Variadic method.
+func (m fakeMethod) VariadicParam() fakeVar { return m.Params[len(m.Params)-1] }
+
+// RecordedArgs returns the arguments that a call records, separated by
+// commas: the parameters, with the copy of the variadic parameter that the
+// template makes, since the caller may reuse the slice.
+func (m fakeMethod) RecordedArgs() string {
+	s := joinVars(m.Params, true, false)
+	if m.Variadic {
+		s += "Copy"
+	}
+	return s
+}
+
+// ArgsStruct and ResultsStruct return the struct types that record the
+// parameters of a call, and hold the results of a call.
+func (m fakeMethod) ArgsStruct() string    { return varsStruct(m.Params) }
+func (m fakeMethod) ResultsStruct() string { return varsStruct(m.Results) }
+
+// ArgNames, ResultNames, ArgTypes and ResultParams return the names, types,
+// or names and types of the parameters or results, separated by commas.
+func (m fakeMethod) ArgNames() string     { return joinVars(m.Params, true, false) }
+func (m fakeMethod) ResultNames() string  { return joinVars(m.Results, true, false) }
+func (m fakeMethod) ArgTypes() string     { return joinVars(m.Params, false, true) }
+func (m fakeMethod) ResultParams() string { return joinVars(m.Results, true, true) }
+
+// Fields returns the fields of the value v of ArgsStruct or ResultsStruct,
+// separated by commas.
+func (m fakeMethod) Fields(v string, results bool) string {
+	vars := m.Params
+	if results {
+		vars = m.Results
+	}
+	var s []string
+	for _, p := range vars {
+		s = append(s, v+"."+p.Name)
+	}
+	return strings.Join(s, ", ")
+}
+
+func varsStruct(vars []fakeVar) string {
+	if len(vars) == 0 {
+		return "struct{}"
+	}
+	return "struct {\n" + joinVars(vars, true, true, "\n") + "\n}"
+}
+
+func joinVars(vars []fakeVar, names, types bool, sep ...string) string {
+	var s []string
+	for _, v := range vars {
+		switch {
+		case names && types:
+			s = append(s, v.Name+" "+v.Type)
+		case names:
+			s = append(s, v.Name)
+		default:
+			s = append(s, v.Type)
+		}
+	}
+	if len(sep) != 0 {
+		return strings.Join(s, sep[0])
+	}
+	return strings.Join(s, ", ")
+}
+
+func lowerFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[n:]
+}
+
+var fakeTemplate = template.Must(template.New("fake").Parse(`// Code generated by impl fake. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .Imports}}
+	{{.Name}} {{printf "%q" .Path}}
+{{- end}}
+)
+
+// {{.Name}} is a fake {{.Of}} that records its calls.
+type {{.Name}}{{.TypeParams}} struct {
+{{- range .Methods}}
+	{{.Name}}Stub {{.FuncType}}
+	{{.Field}}Mutex sync.RWMutex
+	{{.Field}}ArgsForCall []{{.ArgsStruct}}
+{{- if .Results}}
+	{{.Field}}Returns {{.ResultsStruct}}
+	{{.Field}}ReturnsOnCall map[int]{{.ResultsStruct}}
+{{- end}}
+{{- end}}
+	invocations map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+{{range .Methods}}
+func (fake *{{$.Name}}{{$.TypeArgs}}) {{.Name}}({{.ParamList}}){{.ResultList}} {
+	fake.{{.Field}}Mutex.Lock()
+{{- if .Results}}
+	ret, specificReturn := fake.{{.Field}}ReturnsOnCall[len(fake.{{.Field}}ArgsForCall)]
+{{- end}}
+{{- if .Variadic}}
+{{- with .VariadicParam}}
+	var {{.Name}}Copy {{.Type}}
+	if {{.Name}} != nil {
+		{{.Name}}Copy = make({{.Type}}, len({{.Name}}))
+		copy({{.Name}}Copy, {{.Name}})
+	}
+{{- end}}
+{{- end}}
+	fake.{{.Field}}ArgsForCall = append(fake.{{.Field}}ArgsForCall, {{.ArgsStruct}}{ {{- .RecordedArgs -}} })
+	stub := fake.{{.Name}}Stub
+{{- if .Results}}
+	fakeReturns := fake.{{.Field}}Returns
+{{- end}}
+	fake.recordInvocation({{printf "%q" .Name}}, []interface{}{ {{- .RecordedArgs -}} })
+	fake.{{.Field}}Mutex.Unlock()
+{{- if .Results}}
+	if stub != nil {
+		return stub({{.CallArgs}})
+	}
+	if specificReturn {
+		return {{.Fields "ret" true}}
+	}
+	return {{.Fields "fakeReturns" true}}
+{{- else}}
+	if stub != nil {
+		stub({{.CallArgs}})
+	}
+{{- end}}
+}
+
+func (fake *{{$.Name}}{{$.TypeArgs}}) {{.Name}}CallCount() int {
+	fake.{{.Field}}Mutex.RLock()
+	defer fake.{{.Field}}Mutex.RUnlock()
+	return len(fake.{{.Field}}ArgsForCall)
+}
+
+func (fake *{{$.Name}}{{$.TypeArgs}}) {{.Name}}Calls(stub {{.FuncType}}) {
+	fake.{{.Field}}Mutex.Lock()
+	defer fake.{{.Field}}Mutex.Unlock()
+	fake.{{.Name}}Stub = stub
+}
+{{- if .Params}}
+
+func (fake *{{$.Name}}{{$.TypeArgs}}) {{.Name}}ArgsForCall(i int) ({{.ArgTypes}}) {
+	fake.{{.Field}}Mutex.RLock()
+	defer fake.{{.Field}}Mutex.RUnlock()
+	argsForCall := fake.{{.Field}}ArgsForCall[i]
+	return {{.Fields "argsForCall" false}}
+}
+{{- end}}
+{{- if .Results}}
+
+func (fake *{{$.Name}}{{$.TypeArgs}}) {{.Name}}Returns({{.ResultParams}}) {
+	fake.{{.Field}}Mutex.Lock()
+	defer fake.{{.Field}}Mutex.Unlock()
+	fake.{{.Name}}Stub = nil
+	fake.{{.Field}}Returns = {{.ResultsStruct}}{ {{- .ResultNames -}} }
+}
+
+func (fake *{{$.Name}}{{$.TypeArgs}}) {{.Name}}ReturnsOnCall(i int, {{.ResultParams}}) {
+	fake.{{.Field}}Mutex.Lock()
+	defer fake.{{.Field}}Mutex.Unlock()
+	fake.{{.Name}}Stub = nil
+	if fake.{{.Field}}ReturnsOnCall == nil {
+		fake.{{.Field}}ReturnsOnCall = make(map[int]{{.ResultsStruct}})
+	}
+	fake.{{.Field}}ReturnsOnCall[i] = {{.ResultsStruct}}{ {{- .ResultNames -}} }
+}
+{{- end}}
+{{end}}
+// Invocations returns the arguments of every call, by method name.
+func (fake *{{.Name}}{{.TypeArgs}}) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	copied := make(map[string][][]interface{}, len(fake.invocations))
+	for name, calls := range fake.invocations {
+		copied[name] = calls
+	}
+	return copied
+}
+
+func (fake *{{.Name}}{{.TypeArgs}}) recordInvocation(name string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = make(map[string][][]interface{})
+	}
+	fake.invocations[name] = append(fake.invocations[name], args)
+}
+{{- if .Interface}}
+
+var _ {{.Interface}} = new({{.Name}})
+{{- end}}
+`))
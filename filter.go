@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"go/build"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// recursiveSuffix at the end of a -path searches the directory and all of
+// the directories below it, like the go tool's ./... pattern.
+const recursiveSuffix = "/..."
+
+// generatedRx matches the comment that marks a file as generated. See
+// https://golang.org/s/generatedcode.
+var generatedRx = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// stringsFlag is a flag.Value that collects the values of a repeatable
+// flag. A comma-separated value adds each element.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	for _, e := range strings.Split(v, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			*s = append(*s, e)
+		}
+	}
+	return nil
+}
+
+// listFlag is a flag.Value that collects the values of a repeatable flag
+// whose values may contain commas.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, "; ")
+}
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// addPathFlags registers on fs the flags that set the paths to search, which
+// resolveScope merges into arg.Path.
+func addPathFlags(fs *flag.FlagSet) {
+	fs.Var(&arg.Path, "path", "absolute or relative path to directory or file; a directory ending in /... is searched recursively; repeatable")
+	fs.StringVar(&arg.PathsFrom, "paths-from", "", `file with a path to search on each line, or "-" for stdin, for example the output of git ls-files`)
+}
+
+// addLoadFlags registers on fs the flags that control which files are
+// loaded.
+func addLoadFlags(fs *flag.FlagSet) {
+	fs.Var(&arg.Exclude, "exclude", "glob of files and directories to skip, matched against the path relative to -path and against the base name; repeatable")
+	fs.Var(&arg.Tags, "tags", "comma-separated build tags; files whose build constraints are not satisfied by the tags and the current GOOS and GOARCH are skipped")
+	fs.BoolVar(&arg.IncludeVendor, "include-vendor", false, "search vendor directories when searching a path recursively")
+	fs.BoolVar(&arg.SkipGenerated, "skip-generated", false, `skip files with a "// Code generated ... DO NOT EDIT." header`)
+	fs.BoolVar(&arg.Std, "std", false, "search the standard library in GOROOT too, type-checked from source")
+	fs.Var(&arg.Scope, "scope", `comma-separated places to search: "std" for the standard library and a path, for example std,./...`)
+	fs.IntVar(&arg.Jobs, "j", 0, "maximum number of files to parse or packages to type-check in parallel; 0 means the number of CPUs")
+}
+
+// recursiveRoot returns the directory to walk if path ends with
+// recursiveSuffix.
+func recursiveRoot(p string) (string, bool) {
+	p = filepath.ToSlash(p)
+	if p == "..." {
+		return ".", true
+	}
+	if !strings.HasSuffix(p, recursiveSuffix) {
+		return "", false
+	}
+	root := strings.TrimSuffix(p, recursiveSuffix)
+	if root == "" {
+		root = "/"
+	}
+	return filepath.FromSlash(root), true
+}
+
+// listFiles returns the go files to parse for the directory or file
+// specified by path, in lexical order within each directory. If path ends
+// with recursiveSuffix, the files in the directories below it are listed
+// too, or, if the directory has a go.work file, the files in the
+// directories of its workspace modules. A file given as the path itself is
+// never skipped.
+func listFiles(path string, cfg loadConfig) ([]string, error) {
+	if root, ok := recursiveRoot(path); ok {
+		var files []string
+		for _, root := range workspaceRoots(root) {
+			f, err := walkFiles(root, cfg)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, f...)
+		}
+		return files, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, wrapErr("failed to list directory", err)
+	}
+	var files []string
+	for _, d := range entries {
+		p := filepath.Join(path, d.Name())
+		if isGoFile(d) && !cfg.skipFile(path, p) {
+			files = append(files, p)
+		}
+	}
+	return files, nil
+}
+
+// walkFiles returns the go files in root and the directories below it that
+// cfg does not skip, in lexical order within each directory.
+func walkFiles(root string, cfg loadConfig) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && cfg.skipDir(root, p) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isGoFile(d) && !cfg.skipFile(root, p) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, wrapErr("failed to list directory tree", err)
+	}
+	return files, nil
+}
+
+// isGoFile returns whether d is a go source file.
+func isGoFile(d fs.DirEntry) bool {
+	return !d.IsDir() && strings.HasSuffix(d.Name(), ".go")
+}
+
+// skipDir returns whether the directory dir below root should not be
+// searched. Like the go tool, it skips testdata and directories whose names
+// begin with "." or "_". vendor is skipped unless IncludeVendor is set.
+func (cfg loadConfig) skipDir(root, dir string) bool {
+	name := filepath.Base(dir)
+	switch {
+	case strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata":
+		return true
+	case name == "vendor" && !cfg.IncludeVendor:
+		return true
+	}
+	return cfg.excluded(root, dir)
+}
+
+// skipFile returns whether the file should not be parsed.
+func (cfg loadConfig) skipFile(root, file string) bool {
+	if cfg.excluded(root, file) || !cfg.matchTags(file) {
+		return true
+	}
+	return cfg.SkipGenerated && isGenerated(file)
+}
+
+// skipListed returns whether the listed file should not be parsed, as if it
+// had been found by a recursive search of the current directory: the
+// directories on its path below the current directory are checked as well
+// as the file itself. A file outside the current directory is checked by
+// itself.
+func (cfg loadConfig) skipListed(file string) bool {
+	rel := filepath.Clean(file)
+	if wd, err := os.Getwd(); err == nil {
+		if abs, err := filepath.Abs(file); err == nil {
+			if r, err := filepath.Rel(wd, abs); err == nil {
+				rel = r
+			}
+		}
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return cfg.skipFile(filepath.Dir(file), file)
+	}
+	for dir := filepath.Dir(rel); dir != "."; dir = filepath.Dir(dir) {
+		if cfg.skipDir(".", dir) {
+			return true
+		}
+	}
+	return cfg.skipFile(".", rel)
+}
+
+// matchTags returns whether the file's build constraints, including its
+// GOOS and GOARCH file name suffixes, are satisfied by the current GOOS and
+// GOARCH and Tags, as the go tool matches them.
+func (cfg loadConfig) matchTags(file string) bool {
+	ctxt := build.Default
+	ctxt.BuildTags = cfg.Tags
+	ok, err := ctxt.MatchFile(filepath.Dir(file), filepath.Base(file))
+	// Let the parser report an unreadable file.
+	return ok || err != nil
+}
+
+// excluded returns whether p matches one of the Exclude patterns, either by
+// its slash-separated path relative to root or by its base name.
+func (cfg loadConfig) excluded(root, p string) bool {
+	if len(cfg.Exclude) == 0 {
+		return false
+	}
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		rel = p
+	}
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range cfg.Exclude {
+		pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isGenerated returns whether the file has the standard generated code
+// comment before its package clause. Only the file's header is read.
+func isGenerated(file string) bool {
+	f, err := os.Open(file)
+	if err != nil {
+		// Let the parser report the error.
+		return false
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if generatedRx.MatchString(sc.Text()) {
+			return true
+		}
+		if strings.HasPrefix(strings.TrimSpace(sc.Text()), "package ") {
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"os"
+)
+
+const graphUsage = `Print the implementation graph of the go source code: every interface,
+every implementer, and the interfaces embedded by each interface.
+
+Example:
+  impl graph -path ./luci/gae/service/datastore
+
+Flags:`
+
+// Graph is the implementation graph of a set of objects. Edges refer to
+// nodes by their ID.
+type Graph struct {
+	Nodes []Node
+	Edges []Edge
+}
+
+// Node is an interface or an implementer in a Graph.
+type Node struct {
+	ID      int
+	Name    string
+	Kind    string // "interface" or "concrete"
+	Package string
+	Pos     token.Position
+	End     token.Position // The end of the name in the declaration.
+	// Group is the package or file the node is grouped by, with -group-by.
+	Group string `json:",omitempty" xml:",omitempty"`
+	// PointerOnly is true if the node implements at least one interface
+	// only through methods with pointer receivers.
+	PointerOnly bool
+}
+
+// Edge is a relationship between two nodes in a Graph.
+type Edge struct {
+	From int
+	To   int
+	Kind string // "implements" or "embeds"
+	// PointerOnly is true for an "implements" edge if only a pointer to the
+	// From node implements the To node.
+	PointerOnly bool
+}
+
+// Kinds of Node and Edge.
+const (
+	kindInterface  = "interface"
+	kindConcrete   = "concrete"
+	edgeImplements = "implements"
+	edgeEmbeds     = "embeds"
+)
+
+func graphMain(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("graph", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, graphUsage)
+		fs.PrintDefaults()
+	}
+	addCommonFlags(fs, "json")
+	parseFlags(fs, "impl graph", args)
+
+	if err := applyConfig(fs, "impl graph"); err != nil {
+		fatal(err)
+	}
+	if err := checkGraphFlags(); err != nil {
+		fatal(err)
+	}
+
+	st := newQueryStats(arg.Stats)
+	objects, pkgs, err := loadPaths(ctx, arg.Path, argLoadConfig())
+	if err != nil {
+		fatal(err)
+	}
+	st.step("load", fmt.Sprintf("%d objects in %d packages", len(objects), len(pkgs)))
+	idx := newMethodIndex(objects)
+	st.step("index", fmt.Sprintf("%d types, %d method names", len(idx.types), len(idx.byMethod)))
+	interfaces := declaredInterfaces(objects)
+	g := buildGraph(idx, interfaces, arg.ConcreteOnly)
+	st.match(idx, interfaces, arg.ConcreteOnly)
+	st.log()
+	sortGraph(&g, arg.Sort, arg.GroupBy)
+	outputGraph(g, arg.Format)
+	if len(g.Edges) == 0 {
+		os.Exit(exitNoneFound)
+	}
+}
+
+func checkGraphFlags() error {
+	const cmd = "impl graph"
+	if err := resolveScope(cmd); err != nil {
+		return err
+	}
+	switch {
+	case len(arg.Path) == 0 && !arg.Std:
+		return usageErrorf(cmd, "must specify directory to search (-path flag) or -std.")
+	case !contains(formats, arg.Format):
+		return usageErrorf(cmd, "output format should be one of: %s", formatList)
+	}
+	return checkOrderFlags(cmd)
+}
+
+// buildGraph returns the Graph of the distinct interfaces in interfaces, the
+// types in idx that implement each of them, and the embedding relationships
+// between the interfaces in the Graph.
+func buildGraph(idx *methodIndex, interfaces []ObjectIdent, concreteOnly bool) Graph {
+	var g Graph
+	ids := make(map[Char]int)
+	var typs []types.Type // Indexed by node ID.
+
+	node := func(o ObjectIdent) int {
+		c := NewChar(o)
+		if id, ok := ids[c]; ok {
+			return id
+		}
+		kind := kindConcrete
+		if types.IsInterface(o.Type()) {
+			kind = kindInterface
+		}
+		n := Node{
+			ID:   len(g.Nodes),
+			Name: types.TypeString(o.Type(), nil),
+			Kind: kind,
+		}
+		n.Pos, n.End = defRange(o)
+		n.Package = objectPackage(o)
+		ids[c] = n.ID
+		g.Nodes = append(g.Nodes, n)
+		typs = append(typs, o.Type())
+		return n.ID
+	}
+
+	eachImplementer(idx, interfaces, concreteOnly, func(iface ObjectIdent, implementers []Implementer) {
+		to := node(iface)
+		for _, im := range implementers {
+			from := node(im.ObjectIdent)
+			e := Edge{From: from, To: to, Kind: edgeImplements, PointerOnly: im.Receiver == receiverPointer}
+			if e.PointerOnly {
+				g.Nodes[from].PointerOnly = true
+			}
+			g.Edges = append(g.Edges, e)
+		}
+	})
+
+	// Embedding edges are added once all the nodes exist, so that an
+	// embedded interface declared later in objects is still found. An
+	// interface implements the interfaces it embeds, so the embeds edge
+	// replaces the implements edge of the same pair.
+	type pair struct{ from, to int }
+	embeds := make(map[pair]bool)
+	for from, typ := range typs {
+		it, ok := typ.Underlying().(*types.Interface)
+		if !ok {
+			continue
+		}
+		for i := 0; i < it.NumEmbeddeds(); i++ {
+			named, ok := it.EmbeddedType(i).(*types.Named)
+			if !ok {
+				continue
+			}
+			if to, ok := ids[NewChar(named.Obj())]; ok {
+				g.Edges = append(g.Edges, Edge{From: from, To: to, Kind: edgeEmbeds})
+				embeds[pair{from, to}] = true
+			}
+		}
+	}
+	edges := g.Edges[:0]
+	for _, e := range g.Edges {
+		if e.Kind == edgeImplements && embeds[pair{e.From, e.To}] {
+			continue
+		}
+		edges = append(edges, e)
+	}
+	g.Edges = edges
+
+	return g
+}
+
+// declaredInterfaces returns the interface types declared in objs.
+func declaredInterfaces(objs []ObjectIdent) (ifaces []ObjectIdent) {
+	for _, o := range objs {
+		if _, ok := o.Object.(*types.TypeName); ok && types.IsInterface(o.Type()) {
+			ifaces = append(ifaces, o)
+		}
+	}
+	return
+}
+
+// edgeHint returns a note for an edge that only a pointer to the From node
+// implements, and "" otherwise.
+func edgeHint(e Edge) string {
+	if !e.PointerOnly {
+		return ""
+	}
+	return " (only *T implements: pointer receivers)"
+}
+
+// outputGraph prints g in the specified format.
+func outputGraph(g Graph, format string) {
+	style := pathStyle(format)
+	switch format {
+	case "plain":
+		group := ""
+		for _, e := range g.Edges {
+			from, to := g.Nodes[e.From], g.Nodes[e.To]
+			indent := ""
+			if from.Group != "" {
+				if from.Group != group {
+					group = from.Group
+					fmt.Printf("%s:\n", group)
+				}
+				indent = "  "
+			}
+			fmt.Printf("%s%s: %s %s %s%s\n", indent, displayPos(from.Pos, style), from.Name, e.Kind, to.Name, edgeHint(e))
+		}
+	case "quickfix":
+		for _, e := range g.Edges {
+			from, to := g.Nodes[e.From], g.Nodes[e.To]
+			writeQuickfix(os.Stdout, from.Pos, style, "%s %s %s%s", from.Name, e.Kind, to.Name, edgeHint(e))
+		}
+	case "dot", "mermaid", "plantuml":
+		if err := writeDiagram(os.Stdout, g, format); err != nil {
+			fatal(err)
+		}
+	case "json":
+		b, err := json.MarshalIndent(g, "", "  ")
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Printf("%s\n", b)
+	case "xml":
+		b, err := xml.MarshalIndent(g, "", "  ")
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Printf("%s\n", b)
+	}
+}
@@ -3,84 +3,361 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"flag"
 	"fmt"
 	"go/ast"
-	"go/importer"
-	"go/parser"
 	"go/token"
 	"go/types"
+	"io"
 	"log"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"runtime"
 	"strings"
 )
 
 const (
 	usage = `Find the implementers of an interface in go source code.
 
+Usage:
+  impl [flags]
+  impl <command> [flags]
+
 Examples:
   impl -interface discovery.SwaggerSchemaInterface -path ~/go/src/k8s.io/kubernetes/pkg/client/typed/discovery
   impl -interface datastore.RawInterface -path ./luci/gae/service/datastore -format json 
+  impl -method 'Close() error' -method-regex '^Flush' -path ./...
+  impl graph -path ./luci/gae/service/datastore
+  impl dispatch -interface datastore.RawInterface -method Run -path ./luci/gae/service/datastore
+
+Commands:
+  graph    print every interface, implementer, and embedding in the path
+  dispatch list the call sites of an interface method and their possible callees
+  browse   browse the interfaces and implementers in the path in a terminal UI
+  serve    answer implementer queries over HTTP from the path loaded in memory
+  stats    report interface sizes, implementer counts and packages in the path
+  lint     report unimplemented, single-implementer and package-local interfaces
+  diff     compare the implementers in two directories or git revisions
+  fake     generate a fake implementation of an interface for tests
+  extract  print an interface declaration with the methods of a type
+  common   print the interface with the methods common to several types
+  run      run a named query from the project config file, .impl.json
+  config   show the project config file's resolved settings
+
+Run 'impl <command> -h' for details about a command.
 
 Flags:`
 )
 
 var (
 	arg = struct {
-		Path         string
+		Path         listFlag
+		PathsFrom    string
 		Interface    string
+		Methods      listFlag
+		MethodRegex  string
 		Format       string
 		ConcreteOnly bool
+		Usages       bool
+
+		Exclude       stringsFlag
+		Tags          stringsFlag
+		IncludeVendor bool
+		SkipGenerated bool
+		Std           bool
+		Scope         stringsFlag
+		Jobs          int
+
+		Sort    string
+		GroupBy string
+		Stats   bool
+		Paths   string
+
+		Diagnostics string
 	}{}
 	logger = log.New(os.Stderr, "impl: ", 0)
+
+	// formats are the supported values of the -format flag.
+	formats    = append([]string{"plain", "json", "xml", "quickfix"}, diagramFormats...)
+	formatList = "{" + strings.Join(formats, ",") + "}"
+
+	// commands maps subcommand names to their entry points. A subcommand is
+	// passed the arguments that follow its name, and a context that is
+	// cancelled on interrupt.
+	commands = map[string]func(ctx context.Context, args []string){
+		"graph":    graphMain,
+		"dispatch": dispatchMain,
+		"browse":   browseMain,
+		"serve":    serveMain,
+		"stats":    statsMain,
+		"lint":     lintMain,
+		"diff":     diffMain,
+		"fake":     fakeMain,
+		"extract":  extractMain,
+		"common":   commonMain,
+	}
 )
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		// Restore the default behavior once interrupted, so that a second
+		// interrupt exits at once.
+		<-ctx.Done()
+		stop()
+	}()
+
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			cmd(ctx, os.Args[2:])
+			return
+		}
+	}
+	implMain(ctx, os.Args[1:])
+}
+
+// implMain runs the default command, which finds the implementers of an
+// interface.
+func implMain(ctx context.Context, args []string) {
+	flag.CommandLine.Init("impl", flag.ContinueOnError)
 	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, usage)
 		flag.PrintDefaults()
 	}
-	flag.StringVar(&arg.Path, "path", "", "absolute or relative path to directory or file")
-	flag.StringVar(&arg.Interface, "interface", "", "interface name to find implementing types for, format: packageName.interfaceName")
-	flag.StringVar(&arg.Format, "format", "plain", "output format, should be one of: {plain,json,xml}")
-	flag.BoolVar(&arg.ConcreteOnly, "concrete-only", false, "output concrete types only, by default the output contains both interface and concrete types that implement the specified interface")
-	flag.Parse()
-
+	flag.StringVar(&arg.Interface, "interface", "", "interface name to find implementing types for, format: packageName.interfaceName, or an interface literal such as 'interface{ Close() error }'")
+	flag.Var(&arg.Methods, "method", "method signature, such as 'Close() error', to find the named types that have it instead of the implementers of an interface; repeatable, and a type must have every method")
+	flag.StringVar(&arg.MethodRegex, "method-regex", "", "regular expression that at least one method name of a type must match, to find the named types by their methods instead of the implementers of an interface")
+	flag.BoolVar(&arg.Usages, "usages", false, "also list the assignments, call arguments, returns, composite literals and conversions where a concrete type is converted to the interface; type-checks function bodies")
+	addCommonFlags(flag.CommandLine, "plain")
+	parseFlags(flag.CommandLine, "impl", args)
+
+	if err := applyConfig(flag.CommandLine, "impl"); err != nil {
+		fatal(err)
+	}
 	if err := checkFlags(); err != nil {
-		logger.Fatal(err)
+		fatal(err)
 	}
 
-	mainImpl()
+	mainImpl(ctx)
 }
 
-func mainImpl() {
-	objects, err := getObjects(arg.Path)
+func mainImpl(ctx context.Context) {
+	cfg := argLoadConfig()
+	cfg.FuncBodies = arg.Usages
+	st := newQueryStats(arg.Stats)
+	objects, pkgs, err := loadPaths(ctx, arg.Path, cfg)
 	if err != nil {
-		logger.Fatal(err)
+		fatal(err)
+	}
+	st.step("load", fmt.Sprintf("%d objects in %d packages", len(objects), len(pkgs)))
+	idx := newMethodIndex(objects)
+	st.step("index", fmt.Sprintf("%d types, %d method names", len(idx.types), len(idx.byMethod)))
+	if methodQueried() {
+		q, err := newMethodQuery("impl", arg.Methods, arg.MethodRegex, objects)
+		if err != nil {
+			fatal(err)
+		}
+		results := []Result{q.results(idx, arg.ConcreteOnly)}
+		st.step("match", "")
+		st.log()
+		sortResults(results, arg.Sort, arg.GroupBy)
+		output(results, arg.Format)
+		if len(results[0].Implementers) == 0 {
+			os.Exit(exitNoneFound)
+		}
+		return
 	}
-	results := findImplementers(objects, arg.Interface, arg.ConcreteOnly)
+	interfaces, err := findInterfaces("impl", objects, arg.Interface)
+	if err != nil {
+		fatal(err)
+	}
+
+	if contains(diagramFormats, arg.Format) {
+		g := buildGraph(idx, interfaces, arg.ConcreteOnly)
+		st.match(idx, interfaces, arg.ConcreteOnly)
+		st.log()
+		sortGraph(&g, arg.Sort, arg.GroupBy)
+		outputGraph(g, arg.Format)
+		if len(g.Edges) == 0 {
+			os.Exit(exitNoneFound)
+		}
+		return
+	}
+	results := implementersOf(idx, interfaces, arg.ConcreteOnly)
+	st.match(idx, interfaces, arg.ConcreteOnly)
+	if arg.Usages {
+		for i := range results {
+			results[i].Usages = findUsages(pkgs, results[i].iface.Type())
+		}
+		st.step("usages", "")
+	}
+	st.log()
+	sortResults(results, arg.Sort, arg.GroupBy)
 	output(results, arg.Format)
+	for _, r := range results {
+		if len(r.Implementers) != 0 {
+			return
+		}
+	}
+	os.Exit(exitNoneFound)
 }
 
-func checkFlags() error {
+// addCommonFlags registers the flags shared by the default command and the
+// subcommands on fs.
+func addCommonFlags(fs *flag.FlagSet, defaultFormat string) {
+	addPathFlags(fs)
+	fs.StringVar(&arg.Format, "format", defaultFormat, "output format, should be one of: "+formatList)
+	fs.BoolVar(&arg.ConcreteOnly, "concrete-only", false, "output concrete types only, by default the output contains both interface and concrete types that implement the specified interface")
+	fs.StringVar(&arg.Sort, "sort", sortKeys[0], "order of the output, should be one of: {"+strings.Join(sortKeys, ",")+"}")
+	fs.StringVar(&arg.GroupBy, "group-by", "", "group the output by {"+strings.Join(groupKeys, ",")+"}")
+	fs.BoolVar(&arg.Stats, "stats", false, "print the time taken by each step, and by matching without the method index, to stderr")
+	addPathsFlag(fs)
+	addDiagnosticsFlag(fs)
+	addLoadFlags(fs)
+}
+
+// checkOrderFlags checks the -sort, -group-by, -paths and -diagnostics
+// flags. cmd is the name of the command for the help message.
+func checkOrderFlags(cmd string) error {
 	switch {
-	case arg.Path == "":
-		return errors.New(`must specify directory to search (-path flag).
-Run 'impl -h' for details.`)
-	case len(strings.Split(arg.Interface, ".")) != 2:
-		return errors.New(`must specify interface name in format: packageName.interfaceName (-interface flag).
-Run 'impl -h' for details.`)
-	case !contains([]string{"plain", "json", "xml"}, arg.Format):
-		return errors.New(`output format should be one of: {plain,json,xml}
-Run 'impl -h' for details.`)
+	case !contains(sortKeys, arg.Sort):
+		return usageErrorf(cmd, "sort order should be one of: {%s}", strings.Join(sortKeys, ","))
+	case arg.GroupBy != "" && !contains(groupKeys, arg.GroupBy):
+		return usageErrorf(cmd, "grouping should be one of: {%s}", strings.Join(groupKeys, ","))
+	}
+	if err := checkPathsFlag(cmd); err != nil {
+		return err
+	}
+	return checkDiagnosticsFlag(cmd)
+}
+
+// checkDiagnosticsFlag checks the -diagnostics flag. cmd is the name of the
+// command for the help message.
+func checkDiagnosticsFlag(cmd string) error {
+	if !contains(diagnosticsFormats, arg.Diagnostics) {
+		// Report this one in plain text, since the requested format is
+		// unknown.
+		arg.Diagnostics = diagnosticsFormats[0]
+		return usageErrorf(cmd, "diagnostics format should be one of: {%s}", strings.Join(diagnosticsFormats, ","))
 	}
 	return nil
 }
 
+// addDiagnosticsFlag registers the -diagnostics flag in fs.
+func addDiagnosticsFlag(fs *flag.FlagSet) {
+	fs.StringVar(&arg.Diagnostics, "diagnostics", diagnosticsFormats[0], "format of errors written to stderr, should be one of: {"+strings.Join(diagnosticsFormats, ",")+"}")
+}
+
+// parseFlags parses args into fs, which must use flag.ContinueOnError. An
+// invalid flag is reported by fatal as a usage error, in the format of the
+// -diagnostics flag even if it comes after the invalid flag; -h prints the
+// usage and exits. cmd is the name of the command for the help message.
+func parseFlags(fs *flag.FlagSet, cmd string, args []string) {
+	// Keep the flag package from printing the error and the usage.
+	usage := fs.Usage
+	fs.Usage = func() {}
+	fs.SetOutput(io.Discard)
+	err := fs.Parse(args)
+	fs.Usage = usage
+	fs.SetOutput(nil)
+
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		fs.Usage()
+		os.Exit(0)
+	case err != nil:
+		if d := diagnosticsArg(args); contains(diagnosticsFormats, d) {
+			arg.Diagnostics = d
+		}
+		fatal(usageErrorf(cmd, "%v", err))
+	}
+}
+
+// diagnosticsArg returns the value of the last -diagnostics flag in args,
+// which may not have been parsed, or "" if there is none.
+func diagnosticsArg(args []string) string {
+	var d string
+	for i, a := range args {
+		if a == "--" {
+			break
+		}
+		if !strings.HasPrefix(a, "-") {
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		switch {
+		case name != "diagnostics":
+		case ok:
+			d = value
+		case i+1 < len(args):
+			d = args[i+1]
+		}
+	}
+	return d
+}
+
+// argLoadConfig returns the loadConfig specified by the flags.
+func argLoadConfig() loadConfig {
+	jobs := arg.Jobs
+	if jobs == 0 {
+		jobs = runtime.NumCPU()
+	}
+	return loadConfig{
+		Exclude:       arg.Exclude,
+		Tags:          arg.Tags,
+		IncludeVendor: arg.IncludeVendor,
+		SkipGenerated: arg.SkipGenerated,
+		Std:           arg.Std,
+		Jobs:          jobs,
+	}
+}
+
+func checkFlags() error {
+	const cmd = "impl"
+	if err := resolveScope(cmd); err != nil {
+		return err
+	}
+	switch {
+	case len(arg.Path) == 0 && !arg.Std:
+		return usageErrorf(cmd, "must specify directory to search (-path flag) or -std.")
+	case methodQueried():
+		return checkMethodFlags(cmd)
+	case isInterfaceLiteral(arg.Interface) && arg.Usages:
+		return usageErrorf(cmd, "-usages cannot be combined with an interface literal, since no value is converted to it.")
+	case !isInterfaceLiteral(arg.Interface) && len(strings.Split(arg.Interface, ".")) != 2:
+		return usageErrorf(cmd, "must specify interface name in format: packageName.interfaceName, or an interface literal (-interface flag).")
+	case !contains(formats, arg.Format):
+		return usageErrorf(cmd, "output format should be one of: %s", formatList)
+	case arg.Usages && contains(diagramFormats, arg.Format):
+		return usageErrorf(cmd, "-usages cannot be combined with the %s output format, since diagrams do not show usages.", arg.Format)
+	}
+	return checkOrderFlags(cmd)
+}
+
+// methodQueried reports whether the flags query the named types by their
+// methods rather than the implementers of an interface.
+func methodQueried() bool {
+	return len(arg.Methods) != 0 || arg.MethodRegex != ""
+}
+
+// checkMethodFlags checks the flags of a query by methods. cmd is the name
+// of the command for the help message.
+func checkMethodFlags(cmd string) error {
+	switch {
+	case arg.Interface != "":
+		return usageErrorf(cmd, "-interface cannot be combined with -method or -method-regex.")
+	case arg.Usages:
+		return usageErrorf(cmd, "-usages cannot be combined with -method or -method-regex.")
+	case contains(diagramFormats, arg.Format) || !contains(formats, arg.Format):
+		return usageErrorf(cmd, "output format of a query by methods should be one of: {plain,json,xml,quickfix}")
+	}
+	return checkOrderFlags(cmd)
+}
+
 // findDef returns position of the declared type for the supplied type.
 // Specific for method receivers, since the Go spec does not allow them to be named
 // pointers.
@@ -92,6 +369,8 @@ func findDef(typ types.Type) token.Pos {
 	switch n := typ.(type) {
 	case *types.Named:
 		return n.Obj().Pos()
+	case *types.Alias:
+		return n.Obj().Pos()
 	case *types.Pointer:
 		return findDef(n.Elem())
 	default:
@@ -99,45 +378,139 @@ func findDef(typ types.Type) token.Pos {
 	}
 }
 
-// findImplementers returns the ObjectIdents in the supplied objects that
+// defRange returns the positions of the start and the end of the name in the
+// declaration of o's type.
+func defRange(o ObjectIdent) (pos, end token.Position) {
+	p := findDef(o.Type())
+	if !p.IsValid() {
+		return pos, end
+	}
+	return o.FileSet.Position(p), o.FileSet.Position(p + token.Pos(len(o.Name())))
+}
+
+// findImplementers returns the named types in the supplied objects that
 // implement targetInterface. targetInterface should be of the form:
-// packageName.InterfaceName.
+// packageName.InterfaceName, or an interface literal such as
+// "interface{ Close() error }". It returns nil if targetInterface is not
+// found or is an invalid literal.
 func findImplementers(objects []ObjectIdent, targetInterface string, concreteOnly bool) []Result {
-	interfaces := filterInterfaces(objects, targetInterface)
-	seen := make(map[Char]CharSet)
+	interfaces, _ := findInterfaces("impl", objects, targetInterface)
+	return implementersOf(newMethodIndex(objects), interfaces, concreteOnly)
+}
+
+// implementersOf returns a Result for each distinct interface in interfaces,
+// listing the named types in idx that implement it.
+func implementersOf(idx *methodIndex, interfaces []ObjectIdent, concreteOnly bool) []Result {
 	var results []Result
+	eachImplementer(idx, interfaces, concreteOnly, func(iface ObjectIdent, implementers []Implementer) {
+		res := Result{Interface: NewResultIdentifier(iface), Implementers: make([]ResultIdentifier, 0), iface: iface}
+		for _, im := range implementers {
+			res.Implementers = append(res.Implementers, im.ResultIdentifier())
+		}
+		results = append(results, res)
+	})
+	return results
+}
+
+// eachImplementer calls fn once for each distinct interface in interfaces
+// with the named types in idx that implement it. Only the candidates from
+// the index are checked.
+func eachImplementer(idx *methodIndex, interfaces []ObjectIdent, concreteOnly bool, fn func(iface ObjectIdent, implementers []Implementer)) {
+	seen := make(CharSet)
 
 	for _, iface := range interfaces {
 		in := NewChar(iface)
-		if _, ok := seen[in]; ok {
+		if seen[in] {
 			// Seen this interface before.
 			continue
 		}
-		seen[in] = make(CharSet)
-		res := Result{Interface: NewResultIdentifier(iface), Implementers: make([]ResultIdentifier, 0)}
+		seen[in] = true
+		var implementers []Implementer
 
-		for _, obj := range objects {
-			o := NewChar(obj)
-			if seen[in][o] {
-				// Seen this interface-object pair before.
-				continue
-			}
-			seen[in][o] = true
+		for _, obj := range idx.candidates(iface.Type().Underlying().(*types.Interface)) {
 			if concreteOnly && types.IsInterface(obj.Type()) {
 				continue
 			}
-			if intuitiveImplements(obj, iface) {
-				res.Implementers = append(res.Implementers, NewResultIdentifier(obj))
+			if im, ok := implementation(obj, iface); ok {
+				implementers = append(implementers, im)
 			}
 		}
-		results = append(results, res)
+		fn(iface, implementers)
 	}
+}
 
-	return results
+// Receivers of an Implementer.
+const (
+	receiverValue   = "T"
+	receiverPointer = "*T"
+	receiverBoth    = "both"
+)
+
+// Implementer is a named type that implements an interface.
+type Implementer struct {
+	ObjectIdent // The declaration of the named type T.
+
+	// Receiver is receiverPointer if only *T implements the interface,
+	// receiverBoth if T and *T do, and receiverValue if T is an interface.
+	Receiver string
+	// PointerMethods are the methods of the interface that only *T has,
+	// if Receiver is receiverPointer.
+	PointerMethods []string
+}
+
+// Type returns the type that implements the interface: *T if only *T does,
+// T otherwise.
+func (im Implementer) Type() types.Type {
+	if im.Receiver == receiverPointer {
+		return types.NewPointer(im.ObjectIdent.Type())
+	}
+	return im.ObjectIdent.Type()
+}
+
+// ResultIdentifier creates a ResultIdentifier from im.
+func (im Implementer) ResultIdentifier() ResultIdentifier {
+	ri := NewResultIdentifier(im.ObjectIdent)
+	ri.Name = types.TypeString(im.Type(), nil)
+	ri.Receiver = im.Receiver
+	ri.PointerMethods = im.PointerMethods
+	return ri
+}
+
+// implementation reports whether the named type declared by obj, or a
+// pointer to it, implements iface, and how.
+func implementation(obj, iface ObjectIdent) (Implementer, bool) {
+	it := iface.Type().Underlying().(*types.Interface)
+	switch {
+	case !intuitiveImplements(obj, iface):
+		ptr := types.NewPointer(obj.Type())
+		if types.IsInterface(obj.Type()) || !types.Implements(ptr, it) {
+			return Implementer{}, false
+		}
+		return Implementer{obj, receiverPointer, pointerMethods(obj.Type(), it)}, true
+	case types.IsInterface(obj.Type()):
+		return Implementer{ObjectIdent: obj, Receiver: receiverValue}, true
+	default:
+		return Implementer{ObjectIdent: obj, Receiver: receiverBoth}, true
+	}
+}
+
+// pointerMethods returns the names of the methods of iface that are not in
+// the method set of typ.
+func pointerMethods(typ types.Type, iface *types.Interface) []string {
+	mset := types.NewMethodSet(typ)
+	var names []string
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		if mset.Lookup(m.Pkg(), m.Name()) == nil {
+			names = append(names, m.Name())
+		}
+	}
+	return names
 }
 
 // Output prints the Result list in the specified format.
 func output(res []Result, format string) {
+	style := pathStyle(format)
 	switch format {
 	case "plain":
 		const sep = ": "
@@ -145,58 +518,169 @@ func output(res []Result, format string) {
 		longest := 0
 		for _, r := range res {
 			for _, ri := range r.Implementers {
-				path := filepath.Base(ri.Pos.String())
+				path := displayPos(ri.Pos, style)
+				if len(path)+len(sep) > longest {
+					longest = len(path) + len(sep)
+				}
+				for _, mm := range ri.Methods {
+					path := displayPos(mm.Pos, style)
+					if len(path)+len(sep) > longest {
+						longest = len(path) + len(sep)
+					}
+				}
+			}
+			for _, u := range r.Usages {
+				path := displayPos(u.Pos, style)
 				if len(path)+len(sep) > longest {
 					longest = len(path) + len(sep)
 				}
 			}
 		}
 		for i, r := range res {
-			if len(r.Implementers) == 0 {
+			if len(r.Implementers) == 0 && r.iface.Object == nil {
+				fmt.Println("No matching types.") // A query by methods.
+			} else if len(r.Implementers) == 0 {
 				fmt.Println("No implementing types.")
 			}
+			group := ""
 			for _, ri := range r.Implementers {
-				path := filepath.Base(ri.Pos.String())
-				fmt.Printf("%-*s%s\n", longest, path+sep, ri.Name)
+				indent := ""
+				if ri.Group != "" {
+					if ri.Group != group {
+						group = ri.Group
+						fmt.Printf("%s:\n", group)
+					}
+					indent = "  "
+				}
+				path := displayPos(ri.Pos, style)
+				fmt.Printf("%s%-*s%s%s\n", indent, longest, path+sep, ri.Name, pointerHint(ri))
+				for _, mm := range ri.Methods {
+					fmt.Printf("%s%-*s  %s\n", indent, longest, displayPos(mm.Pos, style)+sep, mm.Provider)
+				}
+			}
+			if len(r.Usages) != 0 {
+				fmt.Printf("\nUsages of %s:\n", r.Interface.Name)
+			}
+			for _, u := range r.Usages {
+				path := displayPos(u.Pos, style)
+				fmt.Printf("%-*s%s of %s\n", longest, path+sep, u.Kind, u.Type)
 			}
 			if i != len(res)-1 {
 				fmt.Println()
 			}
 		}
+	case "quickfix":
+		for _, r := range res {
+			for _, ri := range r.Implementers {
+				if r.iface.Object == nil {
+					// A query by methods.
+					writeQuickfix(os.Stdout, ri.Pos, style, "%s has %s%s", ri.Name, r.Interface.Name, pointerHint(ri))
+				} else {
+					writeQuickfix(os.Stdout, ri.Pos, style, "%s implements %s%s", ri.Name, r.Interface.Name, pointerHint(ri))
+				}
+				for _, mm := range ri.Methods {
+					writeQuickfix(os.Stdout, mm.Pos, style, "method %s", mm.Provider)
+				}
+			}
+			for _, u := range r.Usages {
+				writeQuickfix(os.Stdout, u.Pos, style, "%s of %s to %s", u.Kind, u.Type, r.Interface.Name)
+			}
+		}
 	case "json":
 		b, err := json.MarshalIndent(res, "", "  ")
 		if err != nil {
-			logger.Fatal(err)
+			fatal(err)
 		}
 		fmt.Printf("%s\n", b)
 	case "xml":
 		b, err := xml.MarshalIndent(res, "", "  ")
 		if err != nil {
-			logger.Fatal(err)
+			fatal(err)
 		}
 		fmt.Printf("%s\n", b)
 	}
 }
 
+// pointerHint returns a note explaining why only *T implements the interface
+// for a pointer-only ResultIdentifier, and "" otherwise.
+func pointerHint(ri ResultIdentifier) string {
+	if ri.Receiver != receiverPointer {
+		return ""
+	}
+	if len(ri.PointerMethods) == 1 {
+		return fmt.Sprintf(" (only *T implements: method %s has a pointer receiver)", ri.PointerMethods[0])
+	}
+	return fmt.Sprintf(" (only *T implements: methods %s have pointer receivers)", strings.Join(ri.PointerMethods, ", "))
+}
+
 // Result represents the final output of the program.
 type Result struct {
 	Interface    ResultIdentifier
 	Implementers []ResultIdentifier
+	Usages       []Usage `json:",omitempty" xml:",omitempty"`
+
+	iface ObjectIdent // The interface's declaration.
 }
 
 // ResultIdentifier is details about the elements in Result.
 // Use NewResultIdentifier to create a ResultIdentifier from an ObjectIdent.
 type ResultIdentifier struct {
-	Name string
-	Pos  token.Position
+	Name    string
+	Kind    string // "interface" or "concrete"
+	// Package is the import path of the package, or its name if the
+	// package is outside a module and GOPATH.
+	Package string
+	// Module and Version are the path and version of the module of the
+	// package, if it is in one. Version is only set for a module in the
+	// module cache; a module in the tree or in a go.work workspace is
+	// used from its sources.
+	Module  string `json:",omitempty" xml:",omitempty"`
+	Version string `json:",omitempty" xml:",omitempty"`
+	Pos     token.Position
+	End     token.Position // The end of the name in the declaration.
+	// Group is the package or file the identifier is grouped by, with
+	// -group-by.
+	Group string `json:",omitempty" xml:",omitempty"`
+
+	// Receiver is set for implementers: "T" for an interface type, "both"
+	// if the named type T and *T implement the interface, and "*T" if only
+	// *T does.
+	Receiver string `json:",omitempty" xml:",omitempty"`
+	// PointerMethods are the interface's methods that T lacks because they
+	// have pointer receivers, if Receiver is "*T".
+	PointerMethods []string `json:",omitempty" xml:",omitempty"`
+	// Methods are the methods that matched a query by methods, with their
+	// positions.
+	Methods []MethodMatch `json:",omitempty" xml:"Method,omitempty"`
 }
 
 // NewResultIdentifier creates a ResultIdentifier from o.
 func NewResultIdentifier(o ObjectIdent) ResultIdentifier {
-	return ResultIdentifier{
+	ri := ResultIdentifier{
 		Name: types.TypeString(o.Type(), nil),
-		Pos:  o.FileSet.Position(findDef(o.Type())),
+		Kind: kindConcrete,
+	}
+	ri.Pos, ri.End = defRange(o)
+	if types.IsInterface(o.Type()) {
+		ri.Kind = kindInterface
+	}
+	ri.Package = objectPackage(o)
+	if o.Module != nil {
+		ri.Module, ri.Version = o.Module.Path, o.Module.Version
+	}
+	return ri
+}
+
+// objectPackage returns the import path of the package of o, or its name if
+// the import path is unknown, or "" if o has no package.
+func objectPackage(o ObjectIdent) string {
+	switch {
+	case o.ImportPath != "":
+		return o.ImportPath
+	case o.Pkg() != nil:
+		return o.Pkg().Path()
 	}
+	return ""
 }
 
 // ObjectIdent is a combination of types.Object, *ast.Ident, and
@@ -205,6 +689,13 @@ type ObjectIdent struct {
 	types.Object
 	Ident   *ast.Ident
 	FileSet *token.FileSet
+	// Module is the module of the object's package, or nil if it is not
+	// in one.
+	Module *Module
+	// ImportPath is the import path of the object's package, or "" if it
+	// is unknown. The path of the types.Package of a loaded package is
+	// its name.
+	ImportPath string
 }
 
 // Char is the set of characteristics required to determine if two identifiers
@@ -250,7 +741,12 @@ func contains(list []string, target string) bool {
 func filterInterfaces(objs []ObjectIdent, name string) (ifaces []ObjectIdent) {
 	for _, o := range objs {
 		typ := o.Type()
-		if types.IsInterface(typ) && types.TypeString(typ, nil) == name {
+		s := types.TypeString(typ, nil)
+		if i := strings.IndexByte(s, '['); i >= 0 {
+			// A generic interface, named without its type parameters.
+			s = s[:i]
+		}
+		if types.IsInterface(typ) && s == name {
 			ifaces = append(ifaces, o)
 		}
 	}
@@ -265,110 +761,3 @@ func intuitiveImplements(obj types.Object, iface types.Object) bool {
 	}
 	return types.Implements(obj.Type(), iface.Type().Underlying().(*types.Interface))
 }
-
-// getObjects combines and sends a ObjectIdent for each types.Object
-// whose ast.ObjKind==Typ found in the package in the supplied path.
-func getObjects(path string) ([]ObjectIdent, error) {
-	fset := token.NewFileSet()
-	m, err := parsePath(path, fset)
-	if err != nil {
-		return nil, err
-	}
-
-	conf := &types.Config{
-		IgnoreFuncBodies:         true,
-		DisableUnusedImportCheck: true,
-		Importer:                 importer.Default(),
-	}
-	errCh := make(chan error)
-	var sharedChs []<-chan ObjectIdent
-	var result []ObjectIdent
-
-	for _, tree := range m {
-		c := make(chan ObjectIdent)
-		sharedChs = append(sharedChs, c)
-		go func(tree *ast.Package) {
-			errCh <- getObjectsPkg(tree, conf, fset, c)
-		}(tree)
-	}
-
-	finalCh := converge(sharedChs)
-	for {
-		select {
-		case obj, ok := <-finalCh:
-			if !ok {
-				return result, nil
-			}
-			result = append(result, obj)
-		case err := <-errCh:
-			if err != nil {
-				return nil, err
-			}
-		}
-	}
-}
-
-// parsePath parses the directory or file specified by path and returns the
-// AST of packages.
-func parsePath(path string, fset *token.FileSet) (map[string]*ast.Package, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	info, err := f.Stat()
-	if err != nil {
-		return nil, err
-	}
-
-	var pkgs map[string]*ast.Package // Assigned in either of two branches below.
-
-	if info.IsDir() {
-		pkgs, err = parser.ParseDir(fset, path, nil, 0)
-		if err != nil {
-			return nil, wrapErr("failed to parse directory", err)
-		}
-	} else {
-		file, err := parser.ParseFile(fset, path, nil, 0)
-		if err != nil {
-			return nil, wrapErr("failed to parse file", err)
-		}
-		pkg := &ast.Package{
-			Name: file.Name.Name,
-			Files: map[string]*ast.File{
-				filepath.Base(path): file,
-			},
-		}
-		pkgs = map[string]*ast.Package{pkg.Name: pkg}
-	}
-
-	return pkgs, nil
-}
-
-func getObjectsPkg(tree *ast.Package, conf *types.Config, fset *token.FileSet, ch chan<- ObjectIdent) error {
-	info := types.Info{
-		Defs: make(map[*ast.Ident]types.Object),
-	}
-
-	pkgName := tree.Name
-	files := make([]*ast.File, 0, len(tree.Files))
-	for _, f := range tree.Files {
-		files = append(files, f)
-	}
-
-	if _, err := conf.Check(pkgName, fset, files, &info); err != nil {
-		// Related: https://github.com/golang/go/issues/9702
-		return wrapErr(`type-checks failed. Make sure dependencies are completely installed`, err)
-	}
-
-	go func() {
-		defer close(ch)
-		for ident, obj := range info.Defs {
-			if obj == nil || ident.Obj == nil {
-				continue
-			}
-			ch <- ObjectIdent{obj, ident, fset}
-		}
-	}()
-
-	return nil
-}
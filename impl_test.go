@@ -1,7 +1,23 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -44,13 +60,26 @@ func (tr TestableResults) Matches(expected ...TestableExpect) {
 }
 
 func doTest(path, targetInterface string, concreteOnly bool) (TestableResults, error) {
-	objects, err := getObjects(path)
+	return doTestConfig(path, targetInterface, concreteOnly, loadConfig{})
+}
+
+func doTestConfig(path, targetInterface string, concreteOnly bool, cfg loadConfig) (TestableResults, error) {
+	objects, _, err := loadPath(context.Background(), path, cfg)
 	if err != nil {
 		return nil, err
 	}
 	return TestableResults(findImplementers(objects, targetInterface, concreteOnly)), nil
 }
 
+// edges returns the edges in g in the form "From Kind To".
+func edges(g Graph) []string {
+	var s []string
+	for _, e := range g.Edges {
+		s = append(s, g.Nodes[e.From].Name+" "+e.Kind+" "+g.Nodes[e.To].Name)
+	}
+	return s
+}
+
 func TestImpl(t *testing.T) {
 	t.Parallel()
 
@@ -144,7 +173,6 @@ func TestImpl(t *testing.T) {
 					TestableExpect{"testpkg.Landmass", filepath.Join("internal", "testdata", "p3", "p3.go")},
 					TestableExpect{"testpkg.p", filepath.Join("internal", "testdata", "p3", "p3.go")},
 					TestableExpect{"testpkg.Fjord", filepath.Join("internal", "testdata", "p3", "p3.go")},
-					TestableExpect{"*testpkg.Fjord", filepath.Join("internal", "testdata", "p3", "p3.go")},
 				)
 			})
 
@@ -162,5 +190,1323 @@ func TestImpl(t *testing.T) {
 				)
 			})
 		})
+
+		Convey("receivers", func() {
+			Convey("value and pointer", func() {
+				tr, err := doTest(filepath.Join("internal", "testdata", "p3"), "testpkg.Planet", true)
+				So(err, ShouldBeNil)
+				So(tr[0].Implementers, ShouldHaveLength, 1)
+				So(tr[0].Implementers[0].Receiver, ShouldEqual, receiverBoth)
+				So(tr[0].Implementers[0].PointerMethods, ShouldBeEmpty)
+			})
+
+			Convey("pointer only", func() {
+				tr, err := doTest(filepath.Join("internal", "testdata", "p1"), "p1.DrinkerDiner", true)
+				So(err, ShouldBeNil)
+				So(tr[0].Implementers, ShouldHaveLength, 1)
+				So(tr[0].Implementers[0].Name, ShouldEqual, "*p1.Arthur")
+				So(tr[0].Implementers[0].Receiver, ShouldEqual, receiverPointer)
+				So(tr[0].Implementers[0].PointerMethods, ShouldResemble, []string{"Dine"})
+				So(pointerHint(tr[0].Implementers[0]), ShouldContainSubstring, "method Dine has a pointer receiver")
+			})
+
+			Convey("interface", func() {
+				tr, err := doTest(filepath.Join("internal", "testdata", "p1"), "p1.Diner1", false)
+				So(err, ShouldBeNil)
+				for _, im := range tr[0].Implementers {
+					if im.Name == "p1.Diner2" {
+						So(im.Receiver, ShouldEqual, receiverValue)
+					}
+				}
+			})
+		})
+
+		Convey("filters", func() {
+			dir := filepath.Join("internal", "testdata", "filter")
+			recursive := dir + recursiveSuffix
+			expect := func(name, rel string) TestableExpect {
+				return TestableExpect{name, filepath.Join(dir, filepath.FromSlash(rel))}
+			}
+
+			Convey("recursive", func() {
+				tr, err := doTestConfig(recursive, "filter.Foo", false, loadConfig{})
+				So(err, ShouldBeNil)
+				tr.Matches(
+					expect("filter.A", "filter.go"),
+					expect("filter.Gen", "gen.go"),
+					expect("filter.MockFoo", "mock_foo.go"),
+					expect("sub.S", "sub/sub.go"),
+				)
+			})
+
+			Convey("include vendor", func() {
+				tr, err := doTestConfig(recursive, "filter.Foo", false, loadConfig{IncludeVendor: true})
+				So(err, ShouldBeNil)
+				tr.Matches(
+					expect("filter.A", "filter.go"),
+					expect("filter.Gen", "gen.go"),
+					expect("filter.MockFoo", "mock_foo.go"),
+					expect("sub.S", "sub/sub.go"),
+					expect("v.V", "vendor/v/v.go"),
+				)
+			})
+
+			Convey("skip generated", func() {
+				tr, err := doTestConfig(recursive, "filter.Foo", false, loadConfig{SkipGenerated: true})
+				So(err, ShouldBeNil)
+				tr.Matches(
+					expect("filter.A", "filter.go"),
+					expect("filter.MockFoo", "mock_foo.go"),
+					expect("sub.S", "sub/sub.go"),
+				)
+			})
+
+			Convey("exclude", func() {
+				tr, err := doTestConfig(recursive, "filter.Foo", false, loadConfig{Exclude: []string{"mock_*.go", "sub"}})
+				So(err, ShouldBeNil)
+				tr.Matches(
+					expect("filter.A", "filter.go"),
+					expect("filter.Gen", "gen.go"),
+				)
+			})
+
+			Convey("tags", func() {
+				path := filepath.Join("internal", "testdata", "tags")
+				integration := TestableExpect{"tags.Integration", filepath.Join(path, "integration.go")}
+				plain := TestableExpect{"tags.Plain", filepath.Join(path, "tags.go")}
+				// The Term declared for every GOOS is not redeclared.
+				tr, err := doTestConfig(path, "tags.Foo", true, loadConfig{})
+				So(err, ShouldBeNil)
+				tr.Matches(plain)
+				tr, err = doTestConfig(path, "tags.Foo", true, loadConfig{Tags: []string{"other"}})
+				So(err, ShouldBeNil)
+				tr.Matches(plain)
+				tr, err = doTestConfig(path, "tags.Foo", true, loadConfig{Tags: []string{"other", "integration"}})
+				So(err, ShouldBeNil)
+				tr.Matches(integration, plain)
+			})
+		})
+
+		Convey("config", func() {
+			dir, err := filepath.Abs(filepath.Join("internal", "testdata", "config"))
+			So(err, ShouldBeNil)
+
+			Convey("found from a subdirectory", func() {
+				c, err := findConfig(filepath.Join(dir, "sub"))
+				So(err, ShouldBeNil)
+				So(c, ShouldNotBeNil)
+				So(c.File, ShouldEqual, filepath.Join(dir, configName))
+				So(c.Defaults["path"], ShouldResemble, flagValues{filepath.Join(dir, "sub") + recursiveSuffix})
+				So(c.Defaults["concrete-only"], ShouldResemble, flagValues{"true"})
+				So(c.Defaults["exclude"], ShouldResemble, flagValues{"mock_*.go", "gen"})
+				So(c.Defaults["j"], ShouldResemble, flagValues{"2"})
+				So(c.queryNames(), ShouldResemble, []string{"foos", "graph", "listed"})
+				So(c.Queries["foos"].Command, ShouldEqual, "")
+				So(c.Queries["graph"].Command, ShouldEqual, "graph")
+				So(c.Queries["graph"].Flags["scope"], ShouldResemble, flagValues{"std," + dir})
+				// Paths are not comma-separated.
+				So(c.Queries["listed"].Flags["path"], ShouldResemble, flagValues{filepath.Join(dir, "a,b"), filepath.Join(dir, "sub")})
+			})
+
+			Convey("none", func() {
+				c, err := findConfig(string(filepath.Separator))
+				So(err, ShouldBeNil)
+				So(c, ShouldBeNil)
+			})
+
+			Convey("command line overrides the query and defaults", func() {
+				c, err := findConfig(dir)
+				So(err, ShouldBeNil)
+				fs := flag.NewFlagSet("test", flag.ContinueOnError)
+				var (
+					format, path string
+					concreteOnly bool
+					exclude      stringsFlag
+				)
+				fs.StringVar(&format, "format", "plain", "")
+				fs.StringVar(&path, "path", "", "")
+				fs.BoolVar(&concreteOnly, "concrete-only", false, "")
+				fs.Var(&exclude, "exclude", "")
+				So(fs.Parse([]string{"-path", "other"}), ShouldBeNil)
+				So(setFlags(fs, "impl", c.Queries["foos"].Flags, c.Defaults), ShouldBeNil)
+				So(path, ShouldEqual, "other")
+				So(format, ShouldEqual, "json")
+				So(concreteOnly, ShouldBeTrue)
+				So(exclude, ShouldResemble, stringsFlag{"mock_*.go", "gen"})
+			})
+
+			Convey("scope replaces the default path", func() {
+				c, err := findConfig(dir)
+				So(err, ShouldBeNil)
+				fs := flag.NewFlagSet("test", flag.ContinueOnError)
+				var path string
+				var scope stringsFlag
+				fs.StringVar(&path, "path", "", "")
+				fs.Var(&scope, "scope", "")
+				So(setFlags(fs, "impl", c.Queries["graph"].Flags, c.Defaults), ShouldBeNil)
+				So(path, ShouldEqual, "")
+				So(scope, ShouldResemble, stringsFlag{"std", dir})
+
+				// As config show merges them.
+				merged := mergeFlags(c.Queries["graph"].Flags, c.Defaults)
+				So(merged, ShouldNotContainKey, "path")
+				So(merged["scope"], ShouldResemble, flagValues{"std," + dir})
+				So(merged["j"], ShouldResemble, flagValues{"2"})
+				merged = mergeFlags(c.Queries["listed"].Flags, c.Defaults)
+				So(merged["path"], ShouldHaveLength, 2)
+			})
+
+			Convey("invalid value", func() {
+				fs := flag.NewFlagSet("test", flag.ContinueOnError)
+				fs.Bool("concrete-only", false, "")
+				err := setFlags(fs, "impl", map[string]flagValues{"concrete-only": {"maybe"}})
+				So(errors.Is(err, ErrUsage), ShouldBeTrue)
+			})
+		})
+
+		Convey("loading", func() {
+			Convey("local imports", func() {
+				tr, err := doTestConfig(filepath.Join("internal", "testdata", "imports")+recursiveSuffix, "a.Doer", false, loadConfig{Jobs: 4})
+				So(err, ShouldBeNil)
+				tr.Matches(
+					TestableExpect{"b.B", filepath.Join("internal", "testdata", "imports", "b", "b.go")},
+				)
+			})
+
+			Convey("cancelled", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				_, _, err := loadPath(ctx, filepath.Join("internal", "testdata")+recursiveSuffix, loadConfig{Jobs: 4})
+				So(err, ShouldEqual, context.Canceled)
+			})
+
+			Convey("parallel stops at the first error", func() {
+				var mu sync.Mutex
+				calls := 0
+				errStop := errors.New("stop")
+				err := parallel(context.Background(), 1, 10, func(ctx context.Context, i int) error {
+					mu.Lock()
+					calls++
+					mu.Unlock()
+					if i == 3 {
+						return errStop
+					}
+					return nil
+				})
+				So(err, ShouldEqual, errStop)
+				So(calls, ShouldEqual, 4)
+			})
+
+			Convey("parallel cancels running calls", func() {
+				err := parallel(context.Background(), 4, 4, func(ctx context.Context, i int) error {
+					if i == 0 {
+						return errors.New("fail")
+					}
+					<-ctx.Done() // Would block forever without cancellation.
+					return ctx.Err()
+				})
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "fail")
+			})
+		})
+
+		Convey("ordering", func() {
+			path := filepath.Join("internal", "testdata", "filter") + recursiveSuffix
+			names := func(tr TestableResults) []string {
+				var s []string
+				for _, im := range tr[0].Implementers {
+					s = append(s, im.Name)
+				}
+				return s
+			}
+
+			Convey("deterministic", func() {
+				first, err := doTestConfig(path, "filter.Foo", false, loadConfig{Jobs: 4})
+				So(err, ShouldBeNil)
+				sortResults(first, "package", "")
+				So(names(first), ShouldResemble, []string{"filter.A", "filter.Gen", "filter.MockFoo", "sub.S"})
+				for i := 0; i < 5; i++ {
+					tr, err := doTestConfig(path, "filter.Foo", false, loadConfig{Jobs: 4})
+					So(err, ShouldBeNil)
+					sortResults(tr, "package", "")
+					So(names(tr), ShouldResemble, names(first))
+				}
+			})
+
+			Convey("by name", func() {
+				tr, err := doTestConfig(path, "filter.Foo", false, loadConfig{IncludeVendor: true})
+				So(err, ShouldBeNil)
+				sortResults(tr, "name", "")
+				So(names(tr), ShouldResemble, []string{"filter.A", "filter.Gen", "filter.MockFoo", "sub.S", "v.V"})
+			})
+
+			Convey("grouped by file", func() {
+				tr, err := doTestConfig(path, "filter.Foo", false, loadConfig{})
+				So(err, ShouldBeNil)
+				sortResults(tr, "name", "file")
+				for _, im := range tr[0].Implementers {
+					So(im.Group, ShouldEqual, im.Pos.Filename)
+				}
+			})
+
+			Convey("graph", func() {
+				objects, err := getObjects(filepath.Join("internal", "testdata", "p3"))
+				So(err, ShouldBeNil)
+				g := buildGraph(newMethodIndex(objects), declaredInterfaces(objects), false)
+				sortGraph(&g, "kind", "package")
+				So(g.Nodes[len(g.Nodes)-1].Name, ShouldEqual, "testpkg.Fjord")
+				for i, n := range g.Nodes {
+					So(n.ID, ShouldEqual, i)
+					So(n.Group, ShouldEndWith, "internal/testdata/p3")
+				}
+				So(edges(g), ShouldContain, "testpkg.Fjord implements testpkg.Landmass")
+			})
+		})
+
+		Convey("positions", func() {
+			Convey("end of the name", func() {
+				tr, err := doTest(filepath.Join("internal", "testdata", "p1"), "p1.Diner1", false)
+				So(err, ShouldBeNil)
+				for _, ri := range append([]ResultIdentifier{tr[0].Interface}, tr[0].Implementers...) {
+					So(ri.End.Filename, ShouldEqual, ri.Pos.Filename)
+					So(ri.End.Line, ShouldEqual, ri.Pos.Line)
+					name := ri.Name[strings.LastIndex(ri.Name, ".")+1:]
+					So(ri.End.Column-ri.Pos.Column, ShouldEqual, len(name))
+				}
+			})
+
+			Convey("path styles", func() {
+				wd, err := os.Getwd()
+				So(err, ShouldBeNil)
+				pos := token.Position{Filename: filepath.Join("internal", "testdata", "p1", "p1.go"), Line: 5, Column: 6}
+				So(displayPos(pos, "base"), ShouldEqual, "p1.go:5:6")
+				So(displayPos(pos, "rel"), ShouldEqual, pos.Filename+":5:6")
+				So(displayPos(pos, "abs"), ShouldEqual, filepath.Join(wd, pos.Filename)+":5:6")
+				pos.Filename = filepath.Join(wd, pos.Filename)
+				So(displayPos(pos, "rel"), ShouldEqual, filepath.Join("internal", "testdata", "p1", "p1.go")+":5:6")
+				So(displayPos(token.Position{}, "abs"), ShouldEqual, "-")
+			})
+
+			Convey("defaults and quickfix", func() {
+				defer func(paths string) { arg.Paths = paths }(arg.Paths)
+				arg.Paths = ""
+				So(pathStyle("plain"), ShouldEqual, "base")
+				So(pathStyle("quickfix"), ShouldEqual, "rel")
+				arg.Paths = "abs"
+				So(pathStyle("quickfix"), ShouldEqual, "abs")
+				arg.Paths = "full"
+				So(errors.Is(checkPathsFlag("impl"), ErrUsage), ShouldBeTrue)
+
+				var buf bytes.Buffer
+				pos := token.Position{Filename: filepath.Join("a", "b.go"), Line: 3, Column: 7}
+				writeQuickfix(&buf, pos, "rel", "%s implements %s", "a.T", "a.I")
+				So(buf.String(), ShouldEqual, filepath.Join("a", "b.go")+":3:7: a.T implements a.I\n")
+			})
+		})
+
+		Convey("std", func() {
+			paths, err := stdPackages(loadConfig{})
+			So(err, ShouldBeNil)
+			So(paths, ShouldContain, "io")
+			So(paths, ShouldContain, "net/http")
+			So(paths, ShouldNotContain, "builtin")
+			So(paths, ShouldNotContain, "cmd/go")
+			So(paths, ShouldNotContain, "vendor/golang.org/x/net/http/httpguts")
+
+			Convey("scope", func() {
+				defer func(path listFlag, std bool, scope stringsFlag) {
+					arg.Path, arg.Std, arg.Scope = path, std, scope
+				}(arg.Path, arg.Std, arg.Scope)
+
+				arg.Path, arg.Std, arg.Scope = nil, false, stringsFlag{"std", "./..."}
+				So(resolveScope("impl"), ShouldBeNil)
+				So(arg.Std, ShouldBeTrue)
+				So(arg.Path, ShouldResemble, listFlag{"./..."})
+
+				arg.Scope = stringsFlag{"./other", "other/"}
+				So(resolveScope("impl"), ShouldBeNil)
+				So(arg.Path, ShouldResemble, listFlag{"./...", "./other"})
+			})
+		})
+
+		Convey("usages", func() {
+			objects, pkgs, err := loadPath(context.Background(), filepath.Join("internal", "testdata", "usages"), loadConfig{FuncBodies: true})
+			So(err, ShouldBeNil)
+			res := findImplementers(objects, "usages.Foo", false)
+			So(res, ShouldHaveLength, 1)
+
+			kinds := make(map[string]int)
+			for _, u := range findUsages(pkgs, res[0].iface.Type()) {
+				kinds[u.Kind]++
+				So(u.Type, ShouldBeIn, []string{"usages.Arthur", "*usages.Zaphod"})
+			}
+			So(kinds, ShouldResemble, map[string]int{
+				usageAssignment: 2,
+				usageArgument:   3,
+				usageReturn:     2,
+				usageComposite:  2,
+				usageConversion: 1,
+			})
+		})
+
+		Convey("dispatch", func() {
+			objects, pkgs, err := loadPath(context.Background(), filepath.Join("internal", "testdata", "dispatch"), loadConfig{FuncBodies: true})
+			So(err, ShouldBeNil)
+			sites, err := findCallSites(objects, pkgs, "dispatch.Foo", "Baz")
+			So(err, ShouldBeNil)
+
+			callees := make(map[string][]string)
+			for _, s := range sites {
+				for _, c := range s.Callees {
+					callees[s.Method] = append(callees[s.Method], c.Name)
+				}
+			}
+			So(sites, ShouldHaveLength, 4) // f.Baz(1), b.Baz(2), the method value f.Baz, and w.Baz(6).
+			So(callees["dispatch.Bazzer.Baz"], ShouldResemble, []string{"(*dispatch.Zaphod).Baz", "(dispatch.Arthur).Baz", "(dispatch.Marvin).Baz"})
+			So(callees["dispatch.Foo.Baz"], ShouldResemble, []string{"(*dispatch.Zaphod).Baz", "(*dispatch.Zaphod).Baz", "(*dispatch.Zaphod).Baz"})
+			So(sites[3].Pos.Line, ShouldEqual, 47)
+			So(callees, ShouldNotContainKey, "dispatch.Other.Baz")
+
+			_, err = findCallSites(objects, pkgs, "dispatch.Foo", "Missing")
+			So(err, ShouldNotBeNil)
+
+			Convey("interfaces sharing a name", func() {
+				objects, pkgs, err := loadPath(context.Background(), filepath.Join("internal", "testdata", "samename", "..."), loadConfig{FuncBodies: true})
+				So(err, ShouldBeNil)
+				sites, err := findCallSites(objects, pkgs, "util.Closer", "Close")
+				So(err, ShouldBeNil)
+				So(sites, ShouldHaveLength, 2)
+				So(sites[0].Callees, ShouldHaveLength, 2) // (util.Conn).Close and (util.File).Close.
+				So(sites[1].Callees, ShouldHaveLength, 1) // Only util.Conn has Flush.
+
+				_, err = findCallSites(objects, pkgs, "util.Closer", "Flush")
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("packages sharing a name", func() {
+			objects, err := getObjects(filepath.Join("internal", "testdata", "samename", "..."))
+			So(err, ShouldBeNil)
+			res := findImplementers(objects, "util.Closer", false)
+			So(res, ShouldHaveLength, 2)
+			// Packages are identified, and sorted, by import path.
+			So(res[0].Interface.Package, ShouldEndWith, "samename/a")
+			So(res[1].Interface.Package, ShouldEndWith, "samename/b")
+			So(res[0].Implementers[0].Package, ShouldEndWith, "samename/a")
+		})
+
+		Convey("errors", func() {
+			dir := filepath.Join("internal", "testdata", "errors", "testdata")
+
+			Convey("parse", func() {
+				_, _, err := loadPath(context.Background(), filepath.Join(dir, "parse"), loadConfig{})
+				var pe *ParseError
+				So(errors.As(err, &pe), ShouldBeTrue)
+				So(errors.Is(err, ErrLoad), ShouldBeTrue)
+				So(pe.Errors, ShouldNotBeEmpty)
+				So(pe.Errors[0].Pos.Line, ShouldEqual, 5)
+				So(exitCode(err), ShouldEqual, exitLoadError)
+				So(newDiagnostic(err).Kind, ShouldEqual, "parse")
+			})
+
+			Convey("type-check", func() {
+				_, _, err := loadPath(context.Background(), filepath.Join(dir, "typecheck"), loadConfig{})
+				var te *TypeCheckError
+				So(errors.As(err, &te), ShouldBeTrue)
+				So(errors.Is(err, ErrLoad), ShouldBeTrue)
+				So(te.Errors, ShouldHaveLength, 2)
+				So(te.Errors[0].Pos.Line, ShouldEqual, 4)
+				So(te.Errors[1].Pos.Line, ShouldEqual, 8)
+				So(errors.Unwrap(err), ShouldNotBeNil)
+				d := newDiagnostic(err)
+				So(d.Kind, ShouldEqual, "type-check")
+				So(d.Errors, ShouldResemble, te.Errors)
+			})
+
+			Convey("interface not found", func() {
+				objects, pkgs, err := loadPath(context.Background(), filepath.Join("internal", "testdata", "dispatch"), loadConfig{FuncBodies: true})
+				So(err, ShouldBeNil)
+				_, err = findCallSites(objects, pkgs, "dispatch.Nope", "Baz")
+				So(errors.Is(err, ErrInterfaceNotFound), ShouldBeTrue)
+				_, err = findCallSites(objects, pkgs, "dispatch.Foo", "Nope")
+				var nf *InterfaceNotFoundError
+				So(errors.As(err, &nf), ShouldBeTrue)
+				So(nf.Method, ShouldEqual, "Nope")
+				So(exitCode(err), ShouldEqual, exitInterfaceNotFound)
+				So(newDiagnostic(err).Kind, ShouldEqual, "interface-not-found")
+			})
+
+			Convey("usage", func() {
+				defer func(path listFlag, std bool, scope stringsFlag, iface, format string, usages bool) {
+					arg.Path, arg.Std, arg.Scope = path, std, scope
+					arg.Interface, arg.Format, arg.Usages = iface, format, usages
+				}(arg.Path, arg.Std, arg.Scope, arg.Interface, arg.Format, arg.Usages)
+
+				arg.Path, arg.Std, arg.Scope = nil, false, nil
+				err := checkFlags()
+				So(errors.Is(err, ErrUsage), ShouldBeTrue)
+				So(exitCode(err), ShouldEqual, exitUsage)
+				d := newDiagnostic(err)
+				So(d.Kind, ShouldEqual, "usage")
+				So(d.Message, ShouldEqual, "must specify directory to search (-path flag) or -std.")
+
+				// The format of an invalid flag's error, which stops
+				// the parsing before -diagnostics is reached.
+				So(diagnosticsArg([]string{"-bogus", "-diagnostics", "json"}), ShouldEqual, "json")
+				So(diagnosticsArg([]string{"--diagnostics=json", "-x"}), ShouldEqual, "json")
+				So(diagnosticsArg([]string{"-path", "-diagnostics"}), ShouldEqual, "")
+				So(diagnosticsArg([]string{"--", "-diagnostics=json"}), ShouldEqual, "")
+
+				// Diagrams do not show usages.
+				arg.Path, arg.Interface, arg.Usages = listFlag{"."}, "p1.Diner1", true
+				for _, format := range diagramFormats {
+					arg.Format = format
+					So(errors.Is(checkFlags(), ErrUsage), ShouldBeTrue)
+				}
+			})
+		})
+
+		Convey("browse", func() {
+			objects, err := getObjects(filepath.Join("internal", "testdata", "browse"))
+			So(err, ShouldBeNil)
+			store := filterInterfaces(objects, "browse.Store")
+			So(store, ShouldHaveLength, 1)
+
+			Convey("near misses", func() {
+				misses := nearMisses(newMethodIndex(objects), store[0])
+				So(misses, ShouldHaveLength, 2)
+				So(misses[0].Name, ShouldEqual, "browse.ReadOnly")
+				So(misses[0].Missing, ShouldResemble, []string{"Put"})
+				So(misses[1].Name, ShouldEqual, "browse.Legacy")
+				So(misses[1].Missing, ShouldBeEmpty)
+				So(misses[1].WrongSignature, ShouldResemble, []string{"Put: have func(key string, value []byte) error, want func(key string, value string) error"})
+			})
+
+			Convey("explain", func() {
+				var mem ObjectIdent
+				for _, o := range objects {
+					if o.Name() == "Mem" {
+						mem = o
+					}
+				}
+				matches := explain(mem, store[0].Type())
+				So(matches, ShouldHaveLength, 3)
+				So(matches[0].Method, ShouldEqual, "Close")
+				So(matches[0].Provider, ShouldEqual, "(*browse.Mem).Close")
+				So(matches[0].Promoted, ShouldEqual, "Base")
+				So(matches[0].Pointer, ShouldBeTrue)
+				So(matches[1].Method, ShouldEqual, "Get")
+				So(matches[1].Promoted, ShouldEqual, "")
+				So(matches[1].Pos.Line, ShouldEqual, 18)
+			})
+
+			Convey("fuzzy match", func() {
+				_, ok := fuzzyMatch("bst", "browse.Store")
+				So(ok, ShouldBeTrue)
+				_, ok = fuzzyMatch("xyz", "browse.Store")
+				So(ok, ShouldBeFalse)
+				boundary, _ := fuzzyMatch("st", "browse.Store")
+				inner, _ := fuzzyMatch("st", "io.Lister")
+				So(boundary, ShouldBeGreaterThan, inner)
+			})
+
+			Convey("keys", func() {
+				b := newBrowser(objects)
+				So(b.matches, ShouldHaveLength, 1)
+				So(b.entries, ShouldHaveLength, 1)
+
+				b.handleKey(keyEnter)
+				So(b.inEntries, ShouldBeTrue)
+				b.handleKey(keyDown)
+				So(b.entries[b.entryCur].name, ShouldEqual, "*browse.Mem")
+				_, open := b.handleKey(keyEnter)
+				So(open, ShouldNotBeNil)
+				So(open.Line, ShouldEqual, 14)
+
+				b.handleKey(keyCtrlN)
+				So(b.entries, ShouldHaveLength, 2)
+				So(b.entries[0].miss, ShouldNotBeNil)
+
+				b.handleKey(keyEsc)
+				So(b.inEntries, ShouldBeFalse)
+				b.handleKey("x")
+				So(b.matches, ShouldBeEmpty)
+				So(b.entries, ShouldBeEmpty)
+				b.handleKey(keyBackspace)
+				So(b.matches, ShouldHaveLength, 1)
+				quit, _ := b.handleKey(keyCtrlC)
+				So(quit, ShouldBeTrue)
+			})
+
+			Convey("read keys", func() {
+				r := bufio.NewReader(strings.NewReader("a\x1b[B\x1b[A\t\r\x7f\x0f\x03"))
+				var keys []string
+				for {
+					k, err := readKey(r)
+					if err != nil {
+						break
+					}
+					keys = append(keys, k)
+				}
+				So(keys, ShouldResemble, []string{"a", keyDown, keyUp, keyTab, keyEnter, keyBackspace, keyCtrlO, keyCtrlC})
+			})
+
+			Convey("render", func() {
+				b := newBrowser(objects)
+				b.handleKey(keyEnter)
+				b.explain = true
+				var buf bytes.Buffer
+				b.render(&buf, 30, 100)
+				out := buf.String()
+				So(out, ShouldContainSubstring, "Interfaces (1 of 1)")
+				So(out, ShouldContainSubstring, "Implementers of browse.Store (1)")
+				So(out, ShouldContainSubstring, "Close <- (*browse.Mem).Close")
+				So(out, ShouldContainSubstring, "promoted through Base")
+			})
+
+			Convey("editor command", func() {
+				pos := token.Position{Filename: "a.go", Line: 3, Column: 7}
+				So(editorCommand("vim", pos), ShouldResemble, []string{"vim", "+3", "a.go"})
+				So(editorCommand("code -w", pos), ShouldResemble, []string{"code", "-w", "-g", "a.go:3:7"})
+				So(editorCommand(" ", pos), ShouldResemble, []string{"vi", "+3", "a.go"})
+			})
+		})
+
+		Convey("serve", func() {
+			s := &server{paths: []string{filepath.Join("internal", "testdata", "browse")}}
+			reloaded, err := s.refresh(context.Background())
+			So(err, ShouldBeNil)
+			So(reloaded, ShouldBeTrue)
+			h := s.handler()
+			get := func(url string, v interface{}) int {
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+				So(json.Unmarshal(rec.Body.Bytes(), v), ShouldBeNil)
+				return rec.Code
+			}
+
+			Convey("implementers", func() {
+				var res []Result
+				So(get("/implementers?interface=browse.Store&sort=name", &res), ShouldEqual, http.StatusOK)
+				So(res, ShouldHaveLength, 1)
+				So(res[0].Interface.Name, ShouldEqual, "browse.Store")
+				So(res[0].Implementers, ShouldHaveLength, 1)
+				So(res[0].Implementers[0].Name, ShouldEqual, "*browse.Mem")
+				So(res[0].Implementers[0].Receiver, ShouldEqual, receiverPointer)
+
+				lit := url.QueryEscape("interface{ Get(string) (string, error) }")
+				So(get("/implementers?concrete-only=true&interface="+lit, &res), ShouldEqual, http.StatusOK)
+				So(res[0].Interface.Name, ShouldEqual, "interface{Get(string) (string, error)}")
+				So(res[0].Implementers, ShouldHaveLength, 3)
+			})
+
+			Convey("interfaces of", func() {
+				var res []Result
+				So(get("/interfaces-of?type=browse.Mem", &res), ShouldEqual, http.StatusOK)
+				So(res, ShouldHaveLength, 1)
+				So(res[0].Interface.Name, ShouldEqual, "browse.Store")
+				So(get("/interfaces-of?type=browse.Closer", &res), ShouldEqual, http.StatusOK)
+				So(res, ShouldBeEmpty)
+			})
+
+			Convey("near miss", func() {
+				var misses []NearMiss
+				So(get("/near-miss?interface=browse.Store", &misses), ShouldEqual, http.StatusOK)
+				So(misses, ShouldHaveLength, 2)
+				So(misses[0].Name, ShouldEqual, "browse.ReadOnly")
+			})
+
+			Convey("graph", func() {
+				var g Graph
+				So(get("/graph?concrete-only=true&group-by=file", &g), ShouldEqual, http.StatusOK)
+				So(edges(g), ShouldResemble, []string{"browse.Mem implements browse.Store"})
+				So(g.Nodes[0].Group, ShouldNotBeEmpty)
+			})
+
+			Convey("errors", func() {
+				var d Diagnostic
+				So(get("/implementers", &d), ShouldEqual, http.StatusBadRequest)
+				So(d.Kind, ShouldEqual, "usage")
+				So(get("/implementers?interface=browse.Store&sort=size", &d), ShouldEqual, http.StatusBadRequest)
+				So(get("/near-miss?interface=browse.Nope", &d), ShouldEqual, http.StatusNotFound)
+				So(d.Kind, ShouldEqual, "interface-not-found")
+				So(get("/interfaces-of?type=browse.Nope", &d), ShouldEqual, http.StatusNotFound)
+				So(get("/implementers?interface="+url.QueryEscape("interface{ Get(nope.Key) }"), &d), ShouldEqual, http.StatusBadRequest)
+			})
+
+			Convey("concurrent queries", func() {
+				var wg sync.WaitGroup
+				for i := 0; i < 8; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						rec := httptest.NewRecorder()
+						h.ServeHTTP(rec, httptest.NewRequest("GET", "/graph", nil))
+					}()
+				}
+				wg.Wait()
+			})
+
+			Convey("refresh on change", func() {
+				dir, err := os.MkdirTemp("", "impl-serve")
+				So(err, ShouldBeNil)
+				defer os.RemoveAll(dir)
+				file := filepath.Join(dir, "a.go")
+				So(os.WriteFile(file, []byte("package a\n\ntype I interface{ M() }\n"), 0666), ShouldBeNil)
+
+				s := &server{paths: []string{dir}}
+				_, err = s.refresh(context.Background())
+				So(err, ShouldBeNil)
+				reloaded, err := s.refresh(context.Background())
+				So(err, ShouldBeNil)
+				So(reloaded, ShouldBeFalse)
+				So(s.current().idx.types, ShouldHaveLength, 1)
+
+				So(os.WriteFile(file, []byte("package a\n\ntype I interface{ M() }\n\ntype T struct{}\n\nfunc (T) M() {}\n"), 0666), ShouldBeNil)
+				reloaded, err = s.refresh(context.Background())
+				So(err, ShouldBeNil)
+				So(reloaded, ShouldBeTrue)
+				So(s.current().idx.types, ShouldHaveLength, 2)
+			})
+		})
+
+		Convey("stats", func() {
+			objects, err := getObjects(filepath.Join("internal", "testdata", "p1"))
+			So(err, ShouldBeNil)
+			idx := newMethodIndex(objects)
+			p1 := objectPackage(objects[0])
+			So(p1, ShouldEndWith, "internal/testdata/p1")
+
+			Convey("interfaces", func() {
+				st := buildStats(idx, declaredInterfaces(objects), "methods")
+				So(st.Interfaces, ShouldHaveLength, 3)
+				is := st.Interfaces[0]
+				So(is.Name, ShouldEqual, "p1.DrinkerDiner")
+				So(is.Methods, ShouldEqual, 2)
+				So(is.Concrete, ShouldEqual, 1)
+				So(is.Interfaces, ShouldEqual, 0)
+				So(is.ImplementerPackages, ShouldResemble, []string{p1})
+				So(st.Interfaces[1].Name, ShouldEqual, "p1.Diner1")
+				So(st.Interfaces[1].Interfaces, ShouldEqual, 2)
+
+				st = buildStats(idx, declaredInterfaces(objects), "implementers")
+				So(st.Interfaces[2].Name, ShouldEqual, "p1.DrinkerDiner")
+			})
+
+			Convey("packages and totals", func() {
+				st := buildStats(idx, declaredInterfaces(objects), "name")
+				So(st.Packages, ShouldResemble, []PackageStats{{Package: p1, Defines: 3, Implements: 3}})
+				So(st.Totals, ShouldResemble, StatsTotals{Interfaces: 3, Concrete: 1, Packages: 1, Implementations: 3})
+
+				var buf bytes.Buffer
+				outputStats(&buf, st, "plain")
+				So(buf.String(), ShouldContainSubstring, "3 interfaces (0 with no concrete implementer), 1 concrete types, 1 packages, 3 implementations")
+			})
+
+			Convey("unimplemented", func() {
+				objects, err := getObjects(filepath.Join("internal", "testdata", "file1.go"))
+				So(err, ShouldBeNil)
+				st := buildStats(newMethodIndex(objects), declaredInterfaces(objects), "methods")
+				So(st.Totals.Unimplemented, ShouldEqual, 1)
+			})
+
+			Convey("packages sharing a name", func() {
+				objects, err := getObjects(filepath.Join("internal", "testdata", "samename", "..."))
+				So(err, ShouldBeNil)
+				st := buildStats(newMethodIndex(objects), declaredInterfaces(objects), "name")
+				So(st.Packages, ShouldHaveLength, 2)
+				So(st.Totals.Packages, ShouldEqual, 2)
+				for _, p := range st.Packages {
+					So(p.Defines, ShouldEqual, 1)
+				}
+				So(st.Interfaces[0].ImplementerPackages, ShouldHaveLength, 2) // a.Closer is implemented in a and b.
+				So(st.Interfaces[1].ImplementerPackages, ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("lint", func() {
+			objects, pkgs, err := loadPath(context.Background(), filepath.Join("internal", "testdata", "lint")+recursiveSuffix, loadConfig{FuncBodies: true, Comments: true})
+			So(err, ShouldBeNil)
+			rules := func(findings []Finding) []string {
+				var s []string
+				for _, f := range findings {
+					s = append(s, f.Interface+" "+f.Rule+" "+f.Severity)
+				}
+				return s
+			}
+
+			Convey("default severities", func() {
+				findings := lint(objects, pkgs, lintRules)
+				So(rules(findings), ShouldResemble, []string{
+					"shapes.Store package-local info",
+					"shapes.Store unimplemented warning",
+					"shapes.Cache package-local info",
+					"shapes.Cache single-implementer warning",
+					"shapes.Runner package-local info",
+				})
+				So(findings[3].Message, ShouldEqual, "shapes.Cache has a single implementer, shapes.memCache, in the same package")
+				So(findings[3].End.Column-findings[3].Pos.Column, ShouldEqual, len("Cache"))
+			})
+
+			Convey("severity flags", func() {
+				levels, err := lintLevels("impl lint", []string{"unimplemented=error", "package-local=off"})
+				So(err, ShouldBeNil)
+				So(rules(lint(objects, pkgs, levels)), ShouldResemble, []string{
+					"shapes.Store unimplemented error",
+					"shapes.Cache single-implementer warning",
+				})
+
+				_, err = lintLevels("impl lint", []string{"unused=error"})
+				So(errors.Is(err, ErrUsage), ShouldBeTrue)
+				_, err = lintLevels("impl lint", []string{"unimplemented"})
+				So(errors.Is(err, ErrUsage), ShouldBeTrue)
+				_, err = lintLevels("impl lint", []string{"unimplemented=fatal"})
+				So(errors.Is(err, ErrUsage), ShouldBeTrue)
+			})
+
+			Convey("ignore directives", func() {
+				parse := func(comments ...string) []*ast.CommentGroup {
+					cg := &ast.CommentGroup{}
+					for _, c := range comments {
+						cg.List = append(cg.List, &ast.Comment{Text: c})
+					}
+					return []*ast.CommentGroup{nil, cg}
+				}
+				r, ok := ignoredRules(parse("// Doc.", "//impl:ignore"))
+				So(ok, ShouldBeTrue)
+				So(r, ShouldBeEmpty)
+				r, ok = ignoredRules(parse("//impl:ignore unimplemented, package-local"))
+				So(ok, ShouldBeTrue)
+				So(r, ShouldResemble, []string{"unimplemented", "package-local"})
+				_, ok = ignoredRules(parse("//impl:ignored"))
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("diff", func() {
+			defer func(path listFlag, iface string, concreteOnly bool) {
+				arg.Path, arg.Interface, arg.ConcreteOnly = path, iface, concreteOnly
+			}(arg.Path, arg.Interface, arg.ConcreteOnly)
+			arg.Path, arg.Interface, arg.ConcreteOnly = listFlag{"./..."}, "", true
+
+			const baseSrc = "package a\n\ntype I interface{ M() }\n\ntype T struct{}\n\nfunc (T) M() {}\n\ntype V struct{}\n\nfunc (V) M() {}\n"
+			const headSrc = "package a\n\ntype I interface{ M() }\n\ntype T struct{}\n\nfunc (*T) M() {}\n\ntype U struct{}\n\nfunc (U) M() {}\n"
+			write := func(dir, src string) {
+				So(os.MkdirAll(filepath.Join(dir, "a"), 0777), ShouldBeNil)
+				So(os.WriteFile(filepath.Join(dir, "a", "a.go"), []byte(src), 0666), ShouldBeNil)
+			}
+			tmp, err := os.MkdirTemp("", "impl-diff-test")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(tmp)
+
+			check := func(diffs []InterfaceDiff) {
+				So(diffs, ShouldHaveLength, 1)
+				d := diffs[0]
+				So(d.Interface.Name, ShouldEqual, "a.I")
+				So(d.Interface.Pos.Filename, ShouldEqual, filepath.Join("a", "a.go"))
+				So(d.Added, ShouldHaveLength, 1)
+				So(d.Added[0].Name, ShouldEqual, "a.U")
+				So(d.Removed, ShouldHaveLength, 1)
+				So(d.Removed[0].Name, ShouldEqual, "a.V")
+				So(d.Changed, ShouldHaveLength, 1)
+				So(d.Changed[0].Name, ShouldEqual, "*a.T")
+				So(d.Changed[0].BaseReceiver, ShouldEqual, receiverBoth)
+				So(d.Changed[0].Receiver, ShouldEqual, receiverPointer)
+			}
+
+			Convey("directories", func() {
+				base, head := filepath.Join(tmp, "base"), filepath.Join(tmp, "head")
+				write(base, baseSrc)
+				write(head, headSrc)
+				var sides [2][]Result
+				for i, spec := range []string{base, head} {
+					root, cleanup, err := resolveSide(context.Background(), ".", spec)
+					So(err, ShouldBeNil)
+					So(root, ShouldEqual, spec)
+					sides[i], err = sideResults(context.Background(), root)
+					cleanup()
+					So(err, ShouldBeNil)
+				}
+				check(diffResults(sides[0], sides[1]))
+				So(diffResults(sides[1], sides[1]), ShouldBeEmpty)
+
+				sides[0], err = sideResults(context.Background(), filepath.Join(tmp, "none"))
+				So(err, ShouldBeNil)
+				So(sides[0], ShouldBeEmpty)
+			})
+
+			Convey("git revision", func() {
+				if _, err := exec.LookPath("git"); err != nil {
+					return
+				}
+				repo := filepath.Join(tmp, "repo")
+				sub := filepath.Join(repo, "sub")
+				write(sub, baseSrc)
+				run := func(args ...string) {
+					_, err := git(context.Background(), repo, append([]string{"-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+					So(err, ShouldBeNil)
+				}
+				So(exec.Command("git", "init", "-q", repo).Run(), ShouldBeNil)
+				run("add", "-A")
+				run("commit", "-q", "-m", "base")
+				write(sub, headSrc)
+
+				root, cleanup, err := resolveSide(context.Background(), sub, "HEAD")
+				So(err, ShouldBeNil)
+				defer cleanup()
+				base, err := sideResults(context.Background(), root)
+				So(err, ShouldBeNil)
+				head, err := sideResults(context.Background(), sub)
+				So(err, ShouldBeNil)
+				check(diffResults(base, head))
+
+				_, _, err = resolveSide(context.Background(), sub, "no-such-rev")
+				So(errors.Is(err, ErrUsage), ShouldBeTrue)
+			})
+		})
+
+		Convey("fake", func() {
+			src, err := os.ReadFile(filepath.Join("internal", "testdata", "fake", "fake.go"))
+			So(err, ShouldBeNil)
+			tmp, err := os.MkdirTemp("", "impl-fake-test")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(tmp)
+			So(os.WriteFile(filepath.Join(tmp, "go.mod"), []byte("module example.com/f\n"), 0666), ShouldBeNil)
+			So(os.MkdirAll(filepath.Join(tmp, "fake"), 0777), ShouldBeNil)
+			So(os.WriteFile(filepath.Join(tmp, "fake", "fake.go"), src, 0666), ShouldBeNil)
+
+			objects, pkgs, err := loadPath(context.Background(), filepath.Join(tmp, "fake"), loadConfig{})
+			So(err, ShouldBeNil)
+			generate := func(name, out string) string {
+				src, err := generateFake(filterInterfaces(objects, name)[0], pkgs, fakeOptions{Out: out})
+				So(err, ShouldBeNil)
+				So(os.MkdirAll(filepath.Dir(out), 0777), ShouldBeNil)
+				So(os.WriteFile(out, src, 0666), ShouldBeNil)
+				return string(src)
+			}
+
+			Convey("in the interface's package", func() {
+				repo := generate("fake.Repo", filepath.Join(tmp, "fake", "repo_fake.go"))
+				So(repo, ShouldStartWith, "// Code generated by impl fake. DO NOT EDIT.\n\npackage fake\n")
+				So(repo, ShouldContainSubstring, "var _ Repo = new(FakeRepo)")
+				So(repo, ShouldContainSubstring, "func (fake *FakeRepo) Put(arg1 string, arg2 ...int) {")
+				So(repo, ShouldContainSubstring, "func (fake *FakeRepo) PutArgsForCall(i int) (string, []int) {")
+				// The variadic slice is copied, as the caller may reuse it.
+				So(repo, ShouldContainSubstring, "copy(arg2Copy, arg2)")
+				So(repo, ShouldContainSubstring, "fake.putArgsForCall = append(fake.putArgsForCall, struct {\n\t\targ1 string\n\t\targ2 []int\n\t}{arg1, arg2Copy})")
+				So(repo, ShouldContainSubstring, "func (fake *FakeRepo) CloseReturnsOnCall(i int, result1 error) {")
+				cache := generate("fake.Cache", filepath.Join(tmp, "fake", "cache_fake.go"))
+				So(cache, ShouldContainSubstring, "type FakeCache[K comparable, V any] struct {")
+				So(cache, ShouldContainSubstring, "func (fake *FakeCache[K, V]) Load(arg1 K) (V, bool) {")
+
+				// The fakes type-check, and implement the interfaces.
+				objects, _, err := loadPath(context.Background(), filepath.Join(tmp, "fake"), loadConfig{})
+				So(err, ShouldBeNil)
+				res := findImplementers(objects, "fake.Repo", true)
+				So(res[0].Implementers, ShouldHaveLength, 1)
+				So(res[0].Implementers[0].Name, ShouldEqual, "*fake.FakeRepo")
+			})
+
+			Convey("in another package", func() {
+				repo := generate("fake.Repo", filepath.Join(tmp, "fakes", "repo.go"))
+				So(repo, ShouldContainSubstring, "package fakes\n")
+				So(repo, ShouldContainSubstring, `fake2 "example.com/f/fake"`)
+				So(repo, ShouldContainSubstring, "var _ fake2.Repo = new(FakeRepo)")
+
+				_, _, err := loadPath(context.Background(), tmp+recursiveSuffix, loadConfig{})
+				So(err, ShouldBeNil)
+			})
+
+			Convey("method named Invocations", func() {
+				_, err := generateFake(filterInterfaces(objects, "fake.Recorder")[0], pkgs, fakeOptions{Out: filepath.Join(tmp, "fake", "recorder_fake.go")})
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "Invocations")
+			})
+		})
+
+		Convey("extract", func() {
+			dir := filepath.Join("internal", "testdata", "extract")
+			objects, pkgs, err := loadPath(context.Background(), filepath.Join(dir, "db"), loadConfig{Comments: true})
+			So(err, ShouldBeNil)
+
+			_, _, err = findType(objects, "db.Conn")
+			So(errors.Is(err, ErrTypeNotFound), ShouldBeTrue)
+			So(exitCode(err), ShouldEqual, exitInterfaceNotFound)
+			typ, ptr, err := findType(objects, "*db.Client")
+			So(err, ShouldBeNil)
+			So(ptr, ShouldBeTrue)
+
+			names := func(ms []*types.Func) []string {
+				var n []string
+				for _, m := range ms {
+					n = append(n, m.Name())
+				}
+				return n
+			}
+			ms, err := typeMethods(typ, true, nil)
+			So(err, ShouldBeNil)
+			So(names(ms), ShouldResemble, []string{"Exec", "Name", "Ping", "Query"})
+			ms, err = typeMethods(typ, false, nil)
+			So(err, ShouldBeNil)
+			So(names(ms), ShouldResemble, []string{"Name", "Ping"})
+			_, err = typeMethods(typ, true, []string{"Exec", "close"})
+			So(errors.Is(err, ErrTypeNotFound), ShouldBeTrue)
+
+			Convey("declaration", func() {
+				ms, err := typeMethods(typ, true, []string{"Query", "Ping"})
+				So(err, ShouldBeNil)
+				src, err := extractInterface(typ, true, "Querier", ms, methodDocs(pkgs), importPaths(pkgs))
+				So(err, ShouldBeNil)
+				So(string(src), ShouldEqual, `import (
+	"context"
+)
+
+// Querier is extracted from *db.Client, which implements it.
+type Querier interface {
+	// Ping checks the connection.
+	Ping(ctx context.Context) error
+	// Query runs q.
+	//
+	// It returns the matching rows.
+	Query(ctx context.Context, q string, args ...interface{}) (*Rows, error)
+}
+`)
+			})
+
+			Convey("imports", func() {
+				objects, pkgs, err := loadPath(context.Background(), dir+recursiveSuffix, loadConfig{})
+				So(err, ShouldBeNil)
+				typ, _, err := findType(objects, "api.Server")
+				So(err, ShouldBeNil)
+				ms, err := typeMethods(typ, false, nil)
+				So(err, ShouldBeNil)
+				src, err := extractInterface(typ, false, "Handler", ms, nil, importPaths(pkgs))
+				So(err, ShouldBeNil)
+				// The two packages named db are told apart.
+				So(string(src), ShouldEqual, `import (
+	"example.com/extract/cache/db"
+	db2 "example.com/extract/db"
+	"net/http"
+)
+
+// Handler is extracted from api.Server, which implements it.
+type Handler interface {
+	Cache() *db.Client
+	DB() *db2.Client
+	ServeHTTP(w http.ResponseWriter, r *http.Request)
+}
+`)
+			})
+
+			Convey("used by", func() {
+				_, users, err := loadPath(context.Background(), dir+recursiveSuffix, loadConfig{FuncBodies: true})
+				So(err, ShouldBeNil)
+				ms, err := typeMethods(typ, true, nil)
+				So(err, ShouldBeNil)
+				// The Client of the other package named db is not typ.
+				So(names(calledMethods(ms, typ, packagePath(importPaths(pkgs), typ.Pkg()), users)), ShouldResemble, []string{"Ping", "Query"})
+				So(names(calledMethods(ms, typ, "example.com/extract/cache/db", users)), ShouldResemble, []string{"Exec"})
+			})
+		})
+
+		Convey("common", func() {
+			objects, err := getObjects(filepath.Join("internal", "testdata", "common"))
+			So(err, ShouldBeNil)
+			c, err := commonInterface(objects, []string{"store.Mem", "*store.Disk", "store.Remote"}, "Store", nil)
+			So(err, ShouldBeNil)
+			So(c.Methods, ShouldResemble, []string{"Close", "Get"})
+			So(c.Declaration, ShouldEqual, `// Store has the methods common to store.Mem, *store.Disk and store.Remote.
+type Store interface {
+	Close() error
+	Get(key string) ([]byte, error)
+}
+`)
+			var names []string
+			for _, ri := range c.Interfaces {
+				names = append(names, ri.Name)
+			}
+			So(names, ShouldResemble, []string{"store.Closer", "store.Getter"})
+
+			Convey("value receivers only", func() {
+				c, err := commonInterface(objects, []string{"store.Mem", "store.Disk"}, "Store", nil)
+				So(err, ShouldBeNil)
+				So(c.Methods, ShouldBeEmpty)
+				So(c.Interfaces, ShouldBeEmpty)
+			})
+
+			Convey("unknown type", func() {
+				_, err := commonInterface(objects, []string{"store.Mem", "store.Tape"}, "Store", nil)
+				So(errors.Is(err, ErrTypeNotFound), ShouldBeTrue)
+			})
+
+			Convey("several packages", func() {
+				objects, pkgs, err := loadPath(context.Background(), filepath.Join("internal", "testdata", "common", "..."), loadConfig{})
+				So(err, ShouldBeNil)
+				c, err := commonInterface(objects, []string{"store.Remote", "*remote.Client"}, "Doer", importPaths(pkgs))
+				So(err, ShouldBeNil)
+				So(c.Declaration, ShouldEqual, `import (
+	"net/http"
+)
+
+// Doer has the methods common to store.Remote and *remote.Client.
+type Doer interface {
+	Close() error
+	Do(req *http.Request) (*http.Response, error)
+	Get(key string) ([]byte, error)
+}
+`)
+			})
+		})
+
+		Convey("multiple paths", func() {
+			p1 := filepath.Join("internal", "testdata", "p1")
+			abs, err := filepath.Abs(p1)
+			So(err, ShouldBeNil)
+			single, pkgs, err := loadPath(context.Background(), p1, loadConfig{})
+			So(err, ShouldBeNil)
+
+			// The same package reached from several paths is loaded once.
+			objects, dup, err := loadPaths(context.Background(), []string{p1, "./" + p1 + "/", abs + recursiveSuffix}, loadConfig{})
+			So(err, ShouldBeNil)
+			So(dup, ShouldHaveLength, len(pkgs))
+			So(objects, ShouldHaveLength, len(single))
+			So(findImplementers(objects, "p1.DrinkerDiner", false)[0].Implementers, ShouldHaveLength,
+				len(findImplementers(single, "p1.DrinkerDiner", false)[0].Implementers))
+
+			// Unrelated roots.
+			objects, _, err = loadPaths(context.Background(), []string{p1, filepath.Join("internal", "testdata", "common")}, loadConfig{})
+			So(err, ShouldBeNil)
+			So(filterInterfaces(objects, "p1.DrinkerDiner"), ShouldHaveLength, 1)
+			So(filterInterfaces(objects, "store.Closer"), ShouldHaveLength, 1)
+
+			Convey("paths from a file", func() {
+				defer func(path listFlag, from string) {
+					arg.Path, arg.PathsFrom = path, from
+				}(arg.Path, arg.PathsFrom)
+
+				f, err := os.CreateTemp("", "impl-paths")
+				So(err, ShouldBeNil)
+				defer os.Remove(f.Name())
+				// Listed files are skipped as a recursive search would
+				// skip them, such as the files in testdata directories,
+				// unlike a listed directory.
+				store := filepath.Join("internal", "testdata", "common", "store.go")
+				parse := filepath.Join("internal", "testdata", "errors", "testdata", "parse", "parse.go")
+				_, err = f.WriteString("impl.go\n\nREADME.md\n" + p1 + "\n" + store + "\n" + parse + "\nimpl.go\n")
+				So(err, ShouldBeNil)
+				So(f.Close(), ShouldBeNil)
+
+				arg.Path, arg.PathsFrom = listFlag{p1}, f.Name()
+				So(resolveScope("impl"), ShouldBeNil)
+				So(arg.Path, ShouldResemble, listFlag{p1, "impl.go"})
+
+				cfg := loadConfig{Exclude: []string{"impl.go"}}
+				So(cfg.skipListed("impl.go"), ShouldBeTrue)
+				So(cfg.skipListed("graph.go"), ShouldBeFalse)
+				So(cfg.skipListed(filepath.Join("vendor", "x", "x.go")), ShouldBeTrue)
+
+				arg.PathsFrom = filepath.Join(os.TempDir(), "impl-no-such-file")
+				So(errors.Is(resolveScope("impl"), ErrUsage), ShouldBeTrue)
+			})
+		})
+
+		Convey("go.work workspace", func() {
+			work := filepath.Join("internal", "testdata", "work")
+
+			// ./... spans the modules in the use directives.
+			objects, err := getObjects(work + recursiveSuffix)
+			So(err, ShouldBeNil)
+			res := findImplementers(objects, "shape.Shape", false)
+			So(res, ShouldHaveLength, 1)
+			So(res[0].Interface.Module, ShouldEqual, "example.com/work/a")
+			So(res[0].Implementers, ShouldHaveLength, 1)
+			So(res[0].Implementers[0].Name, ShouldEqual, "draw.Square")
+			So(res[0].Implementers[0].Module, ShouldEqual, "example.com/work/b")
+			So(res[0].Implementers[0].Version, ShouldEqual, "")
+
+			Convey("imports from the other modules", func() {
+				// module a is type-checked from its sources to find
+				// the method that Square embeds, but its objects are
+				// not loaded.
+				objects, err := getObjects(filepath.Join(work, "b") + recursiveSuffix)
+				So(err, ShouldBeNil)
+				So(filterInterfaces(objects, "shape.Shape"), ShouldBeEmpty)
+				res := findImplementers(objects, "draw.Named", false)
+				So(res[0].Implementers, ShouldHaveLength, 1)
+				So(res[0].Implementers[0].Name, ShouldEqual, "draw.Square")
+
+				os.Setenv("GOWORK", "off")
+				defer os.Unsetenv("GOWORK")
+				_, err = getObjects(filepath.Join(work, "b") + recursiveSuffix)
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("interface literal", func() {
+			objects, err := getObjects(filepath.Join("internal", "testdata", "common"))
+			So(err, ShouldBeNil)
+			So(isInterfaceLiteral("interface{ Close() error }"), ShouldBeTrue)
+			So(isInterfaceLiteral(" interface {}"), ShouldBeTrue)
+			So(isInterfaceLiteral("store.Closer"), ShouldBeFalse)
+
+			res := findImplementers(objects, "interface{ Close() error; List() []string }", true)
+			So(res, ShouldHaveLength, 1)
+			So(res[0].Interface.Name, ShouldEqual, "interface{Close() error; List() []string}")
+			So(res[0].Implementers, ShouldHaveLength, 2)
+			So(res[0].Implementers[0].Name, ShouldEqual, "store.Mem")
+			So(res[0].Implementers[1].Name, ShouldEqual, "*store.Disk")
+
+			// Embedded interfaces from the scope.
+			res = findImplementers(objects, "interface{ store.Getter; store.Closer }", false)
+			So(res[0].Implementers, ShouldHaveLength, 3)
+
+			for _, lit := range []string{"interface{ Close( }", "interface{ Get(nope.Key) }", "interface{ ~int }", "interface{ Close() error; list() }"} {
+				_, err := findInterfaces("impl", objects, lit)
+				So(errors.Is(err, ErrUsage), ShouldBeTrue)
+			}
+			_, err = findInterfaces("impl", objects, "store.Nope")
+			So(errors.Is(err, ErrInterfaceNotFound), ShouldBeTrue)
+		})
+
+		Convey("query by methods", func() {
+			dir := filepath.Join("internal", "testdata", "common")
+			objects, err := getObjects(dir)
+			So(err, ShouldBeNil)
+			idx := newMethodIndex(objects)
+			query := func(sigs []string, regex string, concreteOnly bool) []string {
+				q, err := newMethodQuery("impl", sigs, regex, objects)
+				So(err, ShouldBeNil)
+				var got []string
+				for _, ri := range q.results(idx, concreteOnly).Implementers {
+					var methods []string
+					for _, mm := range ri.Methods {
+						methods = append(methods, mm.Provider)
+					}
+					got = append(got, ri.Name+" "+strings.Join(methods, " "))
+				}
+				return got
+			}
+
+			So(query([]string{"Close() error"}, "", true), ShouldResemble, []string{
+				"store.Mem (store.Mem).Close",
+				"*store.Disk (*store.Disk).Close",
+				"store.Remote (store.Remote).Close",
+			})
+			So(query([]string{"Close() error", "List() []string"}, "", false), ShouldResemble, []string{
+				"store.Mem (store.Mem).Close (store.Mem).List",
+				"*store.Disk (*store.Disk).Close (*store.Disk).List",
+			})
+			So(query(nil, "^(Name|Size)$", true), ShouldResemble, []string{
+				"store.Mem (store.Mem).Name",
+				"*store.Disk (*store.Disk).Size",
+			})
+			So(query([]string{"Get(key string) ([]byte, error)"}, "^Si", true), ShouldResemble, []string{
+				"*store.Disk (*store.Disk).Get (*store.Disk).Size",
+			})
+
+			for _, sig := range []string{"Close(", "Close() nope.Err", "Close(); Open()", "io.Closer", "close()"} {
+				_, err := newMethodQuery("impl", []string{sig}, "", objects)
+				So(errors.Is(err, ErrUsage), ShouldBeTrue)
+			}
+			_, err = newMethodQuery("impl", nil, "(", objects)
+			So(errors.Is(err, ErrUsage), ShouldBeTrue)
+
+			Convey("with the packages in scope", func() {
+				objects, err := getObjects(filepath.Join("internal", "testdata", "extract", "db"))
+				So(err, ShouldBeNil)
+				q, err := newMethodQuery("impl", []string{"Ping(context.Context) error"}, "", objects)
+				So(err, ShouldBeNil)
+				res := q.results(newMethodIndex(objects), true)
+				So(res.Interface.Name, ShouldEqual, "methods Ping(context.Context) error")
+				So(res.Implementers, ShouldHaveLength, 2)
+				So(res.Implementers[1].Methods[0].Promoted, ShouldEqual, "Base")
+			})
+		})
+
+		Convey("standard library scope", func() {
+			// Packages built with cgo import the pseudo-package "C",
+			// which a query's type expression cannot import.
+			objects, _, err := loadPaths(context.Background(), nil, loadConfig{Std: true})
+			So(err, ShouldBeNil)
+			scope := scopePackages(objects)
+			So(scope, ShouldNotContainKey, "C")
+			So(scope, ShouldNotContainKey, "unsafe")
+			So(scope, ShouldContainKey, "io")
+
+			q, err := newMethodQuery("impl", []string{"Close() error"}, "", objects)
+			So(err, ShouldBeNil)
+			So(q.results(newMethodIndex(objects), true).Implementers, ShouldNotBeEmpty)
+
+			res := findImplementers(objects, "interface{ io.Reader; Close() error }", true)
+			So(res, ShouldHaveLength, 1)
+			So(res[0].Implementers, ShouldNotBeEmpty)
+		})
+
+		Convey("method index", func() {
+			Convey("narrows the candidates", func() {
+				objects, err := getObjects(filepath.Join("internal", "testdata", "p1"))
+				So(err, ShouldBeNil)
+				idx := newMethodIndex(objects)
+				interfaces := filterInterfaces(objects, "p1.DrinkerDiner")
+				So(interfaces, ShouldNotBeEmpty)
+				it := interfaces[0].Type().Underlying().(*types.Interface)
+				So(len(idx.candidates(it)), ShouldBeLessThan, len(idx.types))
+			})
+
+			Convey("matches the unindexed search", func() {
+				for _, dir := range []string{"p1", "p2", "p3", "usages", "dispatch", "filter/..."} {
+					objects, err := getObjects(filepath.Join("internal", "testdata", dir))
+					So(err, ShouldBeNil)
+					idx := newMethodIndex(objects)
+					interfaces := declaredInterfaces(objects)
+					So(implementersOf(idx, interfaces, false), ShouldResemble, implementersOf(idx.unindexed(), interfaces, false))
+				}
+			})
+		})
+
+		Convey("graph", func() {
+			objects, err := getObjects(filepath.Join("internal", "testdata", "p3"))
+			So(err, ShouldBeNil)
+			g := buildGraph(newMethodIndex(objects), declaredInterfaces(objects), false)
+			e := edges(g)
+			So(e, ShouldContain, "testpkg.Human embeds testpkg.Planet")
+			So(e, ShouldContain, "testpkg.Landmass embeds testpkg.Planet")
+			So(e, ShouldContain, "testpkg.Fjord implements testpkg.Landmass")
+			// The embeds edge replaces the implements edge.
+			So(e, ShouldNotContain, "testpkg.Human implements testpkg.Planet")
+			So(e, ShouldNotContain, "testpkg.Planet implements testpkg.Planet")
+
+			Convey("concrete types only", func() {
+				g := buildGraph(newMethodIndex(objects), declaredInterfaces(objects), true)
+				So(edges(g), ShouldNotContain, "testpkg.Human implements testpkg.Planet")
+				So(edges(g), ShouldContain, "testpkg.Human embeds testpkg.Planet")
+			})
+
+			Convey("pointer receiver only", func() {
+				var pointerOnly []string
+				for _, e := range g.Edges {
+					if e.PointerOnly {
+						pointerOnly = append(pointerOnly, g.Nodes[e.From].Name+" "+e.Kind+" "+g.Nodes[e.To].Name)
+					}
+				}
+				So(pointerOnly, ShouldContain, "testpkg.Fjord implements testpkg.Landmass")
+				So(pointerOnly, ShouldNotContain, "testpkg.Fjord implements testpkg.Planet")
+			})
+
+			Convey("diagram", func() {
+				var buf bytes.Buffer
+				So(writeDiagram(&buf, g, "dot"), ShouldBeNil)
+				So(buf.String(), ShouldContainSubstring, "subgraph cluster_0")
+				So(buf.String(), ShouldContainSubstring, `[label="implements", style=dashed]`)
+
+				buf.Reset()
+				So(writeDiagram(&buf, g, "mermaid"), ShouldBeNil)
+				So(buf.String(), ShouldContainSubstring, "==>|embeds|")
+
+				buf.Reset()
+				So(writeDiagram(&buf, g, "plantuml"), ShouldBeNil)
+				So(buf.String(), ShouldContainSubstring, `internal/testdata/p3" {`)
+			})
+		})
 	})
 }
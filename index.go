@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"sync/atomic"
+	"time"
+)
+
+// methodIndex is an inverted index from method names to the named types in
+// a set of objects that have a method by that name, which narrows the types
+// that may implement an interface before the full check.
+type methodIndex struct {
+	// types are the distinct named types, in the order of the objects.
+	types []ObjectIdent
+	// sets are the method sets of types: the method set of *T for a
+	// concrete type T, so that pointer-receiver-only implementers are
+	// kept, and of T for an interface.
+	sets []*types.MethodSet
+	// byMethod maps a method's Id, its name qualified by its package if it
+	// is unexported, to the increasing indexes of the types that have it.
+	// It is nil for an index that does not narrow the types.
+	byMethod map[string][]int
+
+	// checks counts the types returned by candidates. It is atomic, since
+	// an index may be shared by concurrent queries.
+	checks atomic.Int64
+}
+
+// newMethodIndex indexes the named types in objects. Each type is reported
+// once, however many objects declare it.
+func newMethodIndex(objects []ObjectIdent) *methodIndex {
+	x := &methodIndex{byMethod: make(map[string][]int)}
+	seen := make(CharSet)
+	for _, obj := range objects {
+		if _, ok := obj.Object.(*types.TypeName); !ok {
+			// Only type declarations are reported; a *T receiver is
+			// covered by the declaration of T.
+			continue
+		}
+		c := NewChar(obj)
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		typ := obj.Type()
+		if !types.IsInterface(typ) {
+			typ = types.NewPointer(typ)
+		}
+		mset := types.NewMethodSet(typ)
+		i := len(x.types)
+		x.types = append(x.types, obj)
+		x.sets = append(x.sets, mset)
+		for j := 0; j < mset.Len(); j++ {
+			id := mset.At(j).Obj().Id()
+			x.byMethod[id] = append(x.byMethod[id], i)
+		}
+	}
+	return x
+}
+
+// unindexed returns an index of the same types that does not narrow them, so
+// that every type is checked against every interface.
+func (x *methodIndex) unindexed() *methodIndex {
+	return &methodIndex{types: x.types, sets: x.sets}
+}
+
+// candidates returns the indexed types, in order, that have every method of
+// it with an identical signature. These are the types that may implement it,
+// through T or *T; the caller still has to check which does.
+func (x *methodIndex) candidates(it *types.Interface) []ObjectIdent {
+	if x.byMethod == nil || it.NumMethods() == 0 {
+		x.checks.Add(int64(len(x.types)))
+		return x.types
+	}
+
+	// Start from the rarest method, so that the fewest types are checked.
+	var rarest []int
+	for i := 0; i < it.NumMethods(); i++ {
+		idxs, ok := x.byMethod[it.Method(i).Id()]
+		if !ok {
+			return nil
+		}
+		if rarest == nil || len(idxs) < len(rarest) {
+			rarest = idxs
+		}
+	}
+
+	var result []ObjectIdent
+	for _, i := range rarest {
+		if x.hasMethods(i, it) {
+			result = append(result, x.types[i])
+		}
+	}
+	x.checks.Add(int64(len(result)))
+	return result
+}
+
+// hasMethods reports whether the method set of the i'th type has every
+// method of it with an identical signature.
+func (x *methodIndex) hasMethods(i int, it *types.Interface) bool {
+	for j := 0; j < it.NumMethods(); j++ {
+		m := it.Method(j)
+		sel := x.sets[i].Lookup(m.Pkg(), m.Name())
+		if sel == nil || !types.Identical(sel.Obj().Type(), m.Type()) {
+			return false
+		}
+	}
+	return true
+}
+
+// queryStats records how long each step of a query takes, for the -stats
+// flag. The methods of a nil *queryStats do nothing, so callers need not
+// check whether -stats is set.
+type queryStats struct {
+	last  time.Time
+	steps []queryStep
+}
+
+type queryStep struct {
+	name, detail string
+	elapsed      time.Duration
+}
+
+// newQueryStats returns a *queryStats that starts timing now, or nil if
+// enabled is false.
+func newQueryStats(enabled bool) *queryStats {
+	if !enabled {
+		return nil
+	}
+	return &queryStats{last: time.Now()}
+}
+
+// step records the time since the previous step as the step name, described
+// by detail.
+func (s *queryStats) step(name, detail string) time.Duration {
+	if s == nil {
+		return 0
+	}
+	now := time.Now()
+	st := queryStep{name, detail, now.Sub(s.last)}
+	s.steps = append(s.steps, st)
+	s.last = now
+	return st.elapsed
+}
+
+// match records the step of matching interfaces against the types in idx,
+// then repeats the match without narrowing the types by the index and
+// records that too, for comparison.
+func (s *queryStats) match(idx *methodIndex, interfaces []ObjectIdent, concreteOnly bool) {
+	if s == nil {
+		return
+	}
+	indexed := s.step("match", fmt.Sprintf("%d types checked", idx.checks.Load()))
+	all := idx.unindexed()
+	eachImplementer(all, interfaces, concreteOnly, func(ObjectIdent, []Implementer) {})
+	unindexed := s.step("no index", fmt.Sprintf("%d types checked", all.checks.Load()))
+	if indexed > 0 {
+		s.steps[len(s.steps)-1].detail += fmt.Sprintf(", %.1fx the indexed time", float64(unindexed)/float64(indexed))
+	}
+}
+
+// log writes the recorded steps to the logger.
+func (s *queryStats) log() {
+	if s == nil {
+		return
+	}
+	for _, st := range s.steps {
+		logger.Printf("stats: %-8s %12s  %s", st.name, st.elapsed.Round(time.Microsecond), st.detail)
+	}
+}
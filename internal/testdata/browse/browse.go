@@ -0,0 +1,37 @@
+package browse
+
+type Store interface {
+	Get(key string) (string, error)
+	Put(key, value string) error
+	Close() error
+}
+
+type Base struct{}
+
+func (*Base) Close() error { return nil }
+
+// Mem implements Store through *Mem, with Close promoted from Base.
+type Mem struct {
+	Base
+}
+
+func (m *Mem) Get(key string) (string, error) { return "", nil }
+func (m *Mem) Put(key, value string) error    { return nil }
+
+// ReadOnly lacks Put.
+type ReadOnly struct{}
+
+func (ReadOnly) Get(key string) (string, error) { return "", nil }
+func (ReadOnly) Close() error                   { return nil }
+
+// Legacy has Put with a different signature.
+type Legacy struct{}
+
+func (Legacy) Get(key string) (string, error)     { return "", nil }
+func (Legacy) Put(key string, value []byte) error { return nil }
+func (Legacy) Close() error                       { return nil }
+
+// Closer has too few of the methods to be a near miss.
+type Closer struct{}
+
+func (Closer) Close() error { return nil }
@@ -0,0 +1,38 @@
+package store
+
+import "net/http"
+
+type Getter interface {
+	Get(key string) ([]byte, error)
+}
+
+type Closer interface {
+	Close() error
+}
+
+type Lister interface {
+	List() []string
+}
+
+type Any interface{}
+
+type Mem struct{}
+
+func (Mem) Get(key string) ([]byte, error) { return nil, nil }
+func (Mem) Close() error                   { return nil }
+func (Mem) List() []string                 { return nil }
+func (Mem) Name() string                   { return "" }
+
+type Disk struct{}
+
+func (*Disk) Get(key string) ([]byte, error) { return nil, nil }
+func (*Disk) Close() error                   { return nil }
+func (*Disk) List() []string                 { return nil }
+func (*Disk) Size() int                      { return 0 }
+
+type Remote struct{}
+
+func (Remote) Get(key string) ([]byte, error)               { return nil, nil }
+func (Remote) Close() error                                 { return nil }
+func (Remote) List(prefix string) []string                  { return nil }
+func (Remote) Do(req *http.Request) (*http.Response, error) { return nil, nil }
@@ -0,0 +1,9 @@
+package sub
+
+type Foo interface {
+	Foo()
+}
+
+type Bar struct{}
+
+func (Bar) Foo() {}
@@ -0,0 +1,48 @@
+package dispatch
+
+/// Interfaces
+
+type Foo interface {
+	Exist()
+	Baz(qux int)
+}
+
+type Bazzer interface {
+	Baz(qux int)
+}
+
+type Other interface {
+	Baz(qux int)
+	Other()
+}
+
+/// Implementers
+
+type Zaphod struct{}
+
+func (z *Zaphod) Exist()      {}
+func (z *Zaphod) Baz(qux int) {}
+
+type Arthur int
+
+func (a Arthur) Baz(qux int) {}
+
+type Marvin struct{}
+
+func (m Marvin) Baz(qux int) {}
+func (m Marvin) Other()      {}
+
+// Wrapper has the methods of the Foo it embeds.
+type Wrapper struct{ Foo }
+
+/// Call sites
+
+func call(f Foo, b Bazzer, o Other, z *Zaphod, w Wrapper) {
+	f.Baz(1)
+	b.Baz(2)
+	o.Baz(3) // Only Marvin; not a call site of Foo.Baz.
+	z.Baz(4) // Static call.
+	g := f.Baz
+	g(5)
+	w.Baz(6) // Dispatches through the embedded Foo.
+}
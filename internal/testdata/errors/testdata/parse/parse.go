@@ -0,0 +1,5 @@
+package parse
+
+type Foo interface {
+	Bar(
+}
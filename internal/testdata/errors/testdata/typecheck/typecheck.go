@@ -0,0 +1,9 @@
+package typecheck
+
+type Foo interface {
+	Bar() Baz
+}
+
+type Qux struct {
+	Quux Corge
+}
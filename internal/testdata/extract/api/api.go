@@ -0,0 +1,16 @@
+package api
+
+import (
+	"context"
+
+	"example.com/extract/db"
+)
+
+func Handle(ctx context.Context, c *db.Client) error {
+	_, err := c.Query(ctx, "select 1")
+	return err
+}
+
+func Check(c *db.Client) func(context.Context) error {
+	return c.Ping
+}
@@ -0,0 +1,16 @@
+package api
+
+import (
+	"net/http"
+
+	cachedb "example.com/extract/cache/db"
+	"example.com/extract/db"
+)
+
+type Server struct{}
+
+func (Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+
+func (Server) Cache() *cachedb.Client { return nil }
+
+func (Server) DB() *db.Client { return nil }
@@ -0,0 +1,11 @@
+package cache
+
+import (
+	"context"
+
+	"example.com/extract/cache/db"
+)
+
+func Flush(ctx context.Context, c *db.Client) error {
+	return c.Exec(ctx, "delete from cache")
+}
@@ -0,0 +1,9 @@
+// Package db has the same name, and a type with the same name, as the
+// other db package.
+package db
+
+import "context"
+
+type Client struct{}
+
+func (c *Client) Exec(ctx context.Context, q string) error { return nil }
@@ -0,0 +1,32 @@
+package fake
+
+import (
+	"context"
+	"io"
+)
+
+/// Interfaces
+
+type Getter interface {
+	Get(ctx context.Context, key string) (string, error)
+}
+
+// Repo embeds interfaces, and has a variadic method.
+type Repo interface {
+	io.Closer
+	Getter
+	Put(key string, values ...int)
+	Len() int
+	Reset()
+}
+
+// Recorder has a method that its fake would declare too.
+type Recorder interface {
+	Invocations() int
+}
+
+// Cache is generic.
+type Cache[K comparable, V any] interface {
+	Load(key K) (V, bool)
+	Store(key K, value V)
+}
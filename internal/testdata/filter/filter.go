@@ -0,0 +1,13 @@
+package filter
+
+/// Interfaces
+
+type Foo interface {
+	Exist()
+}
+
+/// Implementers
+
+type A struct{}
+
+func (A) Exist() {}
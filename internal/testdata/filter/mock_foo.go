@@ -0,0 +1,5 @@
+package filter
+
+type MockFoo struct{}
+
+func (MockFoo) Exist() {}
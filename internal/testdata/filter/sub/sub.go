@@ -0,0 +1,5 @@
+package sub
+
+type S struct{}
+
+func (S) Exist() {}
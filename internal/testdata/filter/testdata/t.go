@@ -0,0 +1,5 @@
+package testdata
+
+type T struct{}
+
+func (T) Exist() {}
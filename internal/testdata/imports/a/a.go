@@ -0,0 +1,9 @@
+package a
+
+/// Interfaces
+
+type Thing struct{}
+
+type Doer interface {
+	Do(t Thing) error
+}
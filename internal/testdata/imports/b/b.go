@@ -0,0 +1,9 @@
+package b
+
+import "example.com/imports/a"
+
+/// Implementers
+
+type B struct{}
+
+func (B) Do(t a.Thing) error { return nil }
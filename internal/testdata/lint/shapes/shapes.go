@@ -0,0 +1,45 @@
+package shapes
+
+/// Interfaces
+
+// Store has no implementers.
+type Store interface {
+	Get(key string) string
+}
+
+// Cache has a single implementer, in this package.
+type Cache interface {
+	Load(key string) int
+}
+
+// Runner has a single implementer, which is accepted.
+//
+//impl:ignore single-implementer
+type Runner interface {
+	Run()
+}
+
+// Shape is referenced outside the package.
+type Shape interface {
+	Area() float64
+}
+
+type hidden interface{ Hide() } //impl:ignore
+
+/// Implementers
+
+type memCache struct{}
+
+func (memCache) Load(key string) int { return 0 }
+
+type runner struct{}
+
+func (*runner) Run() {}
+
+type Square struct{}
+
+func (Square) Area() float64 { return 1 }
+
+type Circle struct{}
+
+func (Circle) Area() float64 { return 3 }
@@ -0,0 +1,10 @@
+package use
+
+import "example.com/lint/shapes"
+
+func Total(s []shapes.Shape) (t float64) {
+	for _, x := range s {
+		t += x.Area()
+	}
+	return t
+}
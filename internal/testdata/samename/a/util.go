@@ -0,0 +1,13 @@
+package util
+
+type Closer interface {
+	Close()
+}
+
+type File struct{}
+
+func (File) Close() {}
+
+func Shutdown(c Closer) {
+	c.Close()
+}
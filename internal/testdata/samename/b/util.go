@@ -0,0 +1,15 @@
+package util
+
+type Closer interface {
+	Close()
+	Flush()
+}
+
+type Conn struct{}
+
+func (Conn) Close() {}
+func (Conn) Flush() {}
+
+func Shutdown(c Closer) {
+	c.Close()
+}
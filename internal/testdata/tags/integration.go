@@ -0,0 +1,7 @@
+//go:build integration
+
+package tags
+
+type Integration struct{}
+
+func (Integration) Foo() {}
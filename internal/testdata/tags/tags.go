@@ -0,0 +1,9 @@
+package tags
+
+type Foo interface {
+	Foo()
+}
+
+type Plain struct{}
+
+func (Plain) Foo() {}
@@ -0,0 +1,5 @@
+//go:build !windows
+
+package tags
+
+type Term struct{ fd int }
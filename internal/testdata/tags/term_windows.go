@@ -0,0 +1,3 @@
+package tags
+
+type Term struct{ handle uintptr }
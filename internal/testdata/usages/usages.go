@@ -0,0 +1,47 @@
+package usages
+
+/// Interfaces
+
+type Foo interface {
+	Exist()
+}
+
+/// Implementers
+
+type Zaphod struct{}
+
+func (z *Zaphod) Exist() {}
+
+type Arthur int
+
+func (a Arthur) Exist() {}
+
+/// Usages
+
+var global Foo = &Zaphod{}
+
+type holder struct {
+	f  Foo
+	fs []Foo
+}
+
+func take(f Foo)              {}
+func takeMany(fs ...Foo)      {}
+func takeOther(i interface{}) {}
+
+func give() Foo {
+	return Arthur(1)
+}
+
+func use() {
+	var f Foo
+	f = &Zaphod{}
+	take(Arthur(2))
+	takeMany(Arthur(3), &Zaphod{})
+	_ = holder{f: Arthur(4), fs: []Foo{&Zaphod{}}}
+	_ = Foo(Arthur(5))
+	_ = func() Foo { return &Zaphod{} }
+	takeOther(&Zaphod{}) // Not a usage of Foo.
+	f = nil              // Not a usage either.
+	take(f)              // Already a Foo.
+}
@@ -0,0 +1,10 @@
+package shape
+
+type Shape interface {
+	Area() float64
+}
+
+// Base is embedded by shapes for their names.
+type Base struct{ name string }
+
+func (b Base) Name() string { return b.name }
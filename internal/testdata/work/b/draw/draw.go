@@ -0,0 +1,16 @@
+package draw
+
+import "example.com/work/a/shape"
+
+type Named interface {
+	Name() string
+}
+
+// Square has the Name method of the shape.Base it embeds, which is only
+// known from the sources of module a.
+type Square struct {
+	shape.Base
+	side float64
+}
+
+func (s Square) Area() float64 { return s.side * s.side }
@@ -0,0 +1,5 @@
+module example.com/work/b
+
+go 1.18
+
+require example.com/work/a v1.0.0
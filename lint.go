@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const lintUsage = `Report interfaces that may not be worth their indirection.
+
+Rules:
+  unimplemented       an interface with no concrete implementer in the path
+  single-implementer  an interface with exactly one concrete implementer, which
+                      is declared in the interface's package
+  package-local       an exported interface that is never referenced outside
+                      its package
+
+Each rule has a severity, one of error, warning, info or off, set with
+-severity rule=level. lint exits 5 if a finding has severity error.
+
+A finding is ignored if the interface's doc comment, or the comment at the
+end of its declaration line, has an //impl:ignore directive. The directive
+may list the rules it ignores:
+  //impl:ignore single-implementer,package-local
+
+Example:
+  impl lint -path ./... -severity single-implementer=error
+
+Flags:`
+
+// Lint rules.
+const (
+	ruleUnimplemented     = "unimplemented"
+	ruleSingleImplementer = "single-implementer"
+	rulePackageLocal      = "package-local"
+)
+
+// lintRules are the lint rules, with their default severities.
+var lintRules = map[string]string{
+	ruleUnimplemented:     severityWarning,
+	ruleSingleImplementer: severityWarning,
+	rulePackageLocal:      severityInfo,
+}
+
+// Severities of a lint rule, most severe first.
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityInfo    = "info"
+	severityOff     = "off"
+)
+
+var severities = []string{severityError, severityWarning, severityInfo, severityOff}
+
+// ignoreDirective is the comment that ignores the findings for a
+// declaration.
+const ignoreDirective = "//impl:ignore"
+
+// Finding is a lint finding about an interface.
+type Finding struct {
+	Rule      string
+	Severity  string
+	Interface string
+	Pos       token.Position
+	End       token.Position
+	Message   string
+}
+
+func lintMain(ctx context.Context, args []string) {
+	var severity stringsFlag
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, lintUsage)
+		fs.PrintDefaults()
+	}
+	addPathFlags(fs)
+	fs.StringVar(&arg.Format, "format", "plain", "output format, should be one of: {plain,json,xml}")
+	fs.Var(&severity, "severity", "severity of a rule, in format: rule=level, where level is one of: {"+strings.Join(severities, ",")+"}; repeatable")
+	addPathsFlag(fs)
+	addDiagnosticsFlag(fs)
+	addLoadFlags(fs)
+	parseFlags(fs, "impl lint", args)
+
+	if err := applyConfig(fs, "impl lint"); err != nil {
+		fatal(err)
+	}
+	levels, err := checkLintFlags(severity)
+	if err != nil {
+		fatal(err)
+	}
+
+	cfg := argLoadConfig()
+	cfg.FuncBodies = true // For the references to each interface.
+	cfg.Comments = true   // For the ignore directives.
+	objects, pkgs, err := loadPaths(ctx, arg.Path, cfg)
+	if err != nil {
+		fatal(err)
+	}
+	findings := lint(objects, pkgs, levels)
+	outputFindings(os.Stdout, findings, arg.Format)
+	for _, f := range findings {
+		if f.Severity == severityError {
+			os.Exit(exitLintErrors)
+		}
+	}
+}
+
+// checkLintFlags checks the flags of impl lint, and returns the severity of
+// each rule.
+func checkLintFlags(severity []string) (map[string]string, error) {
+	const cmd = "impl lint"
+	if err := resolveScope(cmd); err != nil {
+		return nil, err
+	}
+	switch {
+	case len(arg.Path) == 0 && !arg.Std:
+		return nil, usageErrorf(cmd, "must specify directory to search (-path flag) or -std.")
+	case !contains([]string{"plain", "json", "xml"}, arg.Format):
+		return nil, usageErrorf(cmd, "output format should be one of: {plain,json,xml}")
+	}
+	if err := checkPathsFlag(cmd); err != nil {
+		return nil, err
+	}
+	if err := checkDiagnosticsFlag(cmd); err != nil {
+		return nil, err
+	}
+	return lintLevels(cmd, severity)
+}
+
+// lintLevels returns the severity of each rule: the default, or the one set
+// by a rule=level value in severity. cmd is the name of the command for the
+// help message.
+func lintLevels(cmd string, severity []string) (map[string]string, error) {
+	levels := make(map[string]string)
+	for rule, level := range lintRules {
+		levels[rule] = level
+	}
+	for _, s := range severity {
+		rule, level, ok := strings.Cut(s, "=")
+		switch {
+		case !ok:
+			return nil, usageErrorf(cmd, "severity %q should be in format: rule=level", s)
+		case lintRules[rule] == "":
+			return nil, usageErrorf(cmd, "unknown lint rule %q, should be one of: {%s}", rule, strings.Join(lintRuleNames(), ","))
+		case !contains(severities, level):
+			return nil, usageErrorf(cmd, "severity of %s should be one of: {%s}", rule, strings.Join(severities, ","))
+		}
+		levels[rule] = level
+	}
+	return levels, nil
+}
+
+// lintRuleNames returns the names of the lint rules, sorted.
+func lintRuleNames() []string {
+	var names []string
+	for rule := range lintRules {
+		names = append(names, rule)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lint returns the findings about the interfaces declared in objects, with
+// the severities in levels, ordered by position. Rules whose severity is
+// severityOff are not checked. The packages must have been loaded with
+// loadConfig.FuncBodies, for the references to the interfaces, and with
+// loadConfig.Comments, for the ignore directives.
+func lint(objects []ObjectIdent, pkgs []*Package, levels map[string]string) []Finding {
+	ignored := ignoreDirectives(pkgs)
+	referenced := referencedOutside(pkgs)
+
+	var findings []Finding
+	eachImplementer(newMethodIndex(objects), declaredInterfaces(objects), true, func(iface ObjectIdent, implementers []Implementer) {
+		ri := NewResultIdentifier(iface)
+		report := func(rule, format string, a ...interface{}) {
+			level := levels[rule]
+			if level == severityOff || ignored.ignores(ri.Pos, rule) {
+				return
+			}
+			findings = append(findings, Finding{
+				Rule:      rule,
+				Severity:  level,
+				Interface: ri.Name,
+				Pos:       ri.Pos,
+				End:       ri.End,
+				Message:   fmt.Sprintf(format, a...),
+			})
+		}
+
+		switch {
+		case len(implementers) == 0:
+			report(ruleUnimplemented, "%s has no concrete implementers", ri.Name)
+		case len(implementers) == 1 && implementers[0].Pkg() == iface.Pkg():
+			report(ruleSingleImplementer, "%s has a single implementer, %s, in the same package",
+				ri.Name, NewResultIdentifier(implementers[0].ObjectIdent).Name)
+		}
+		if iface.Exported() && !referenced[iface.Object] {
+			report(rulePackageLocal, "%s is exported but not referenced outside package %s", ri.Name, iface.Pkg().Name())
+		}
+	})
+	sort.SliceStable(findings, func(i, j int) bool {
+		if c := comparePos(findings[i].Pos, findings[j].Pos); c != 0 {
+			return c < 0
+		}
+		return findings[i].Rule < findings[j].Rule
+	})
+	return findings
+}
+
+// referencedOutside returns the objects in pkgs that are used in a package
+// other than their own.
+func referencedOutside(pkgs []*Package) map[types.Object]bool {
+	referenced := make(map[types.Object]bool)
+	for _, pkg := range pkgs {
+		for _, obj := range pkg.Info.Uses {
+			if obj.Pkg() != nil && obj.Pkg() != pkg.Types {
+				referenced[obj] = true
+			}
+		}
+	}
+	return referenced
+}
+
+// directives are the ignore directives on the type declarations in a set of
+// packages, keyed by the position of the declared name. A directive holds
+// the rules it ignores, or is empty to ignore all of them.
+type directives map[token.Position][]string
+
+// ignoreDirectives returns the ignore directives in the doc and line
+// comments of the type declarations in pkgs.
+func ignoreDirectives(pkgs []*Package) directives {
+	d := make(directives)
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			for _, decl := range f.Decls {
+				gd, ok := decl.(*ast.GenDecl)
+				if !ok || gd.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gd.Specs {
+					ts := spec.(*ast.TypeSpec)
+					groups := []*ast.CommentGroup{ts.Doc, ts.Comment}
+					if !gd.Lparen.IsValid() {
+						groups = append(groups, gd.Doc)
+					}
+					if rules, ok := ignoredRules(groups); ok {
+						d[pkg.Fset.Position(ts.Name.Pos())] = rules
+					}
+				}
+			}
+		}
+	}
+	return d
+}
+
+// ignoredRules returns the rules ignored by the directives in groups, and
+// whether there is a directive.
+func ignoredRules(groups []*ast.CommentGroup) ([]string, bool) {
+	var rules []string
+	found := false
+	for _, cg := range groups {
+		if cg == nil {
+			continue
+		}
+		for _, c := range cg.List {
+			rest, ok := strings.CutPrefix(c.Text, ignoreDirective)
+			if !ok || (rest != "" && rest[0] != ' ') {
+				continue
+			}
+			if strings.TrimSpace(rest) == "" {
+				return nil, true
+			}
+			found = true
+			for _, r := range strings.Split(rest, ",") {
+				if r = strings.TrimSpace(r); r != "" {
+					rules = append(rules, r)
+				}
+			}
+		}
+	}
+	return rules, found
+}
+
+// ignores reports whether a directive ignores rule for the type declared at
+// pos.
+func (d directives) ignores(pos token.Position, rule string) bool {
+	rules, ok := d[pos]
+	return ok && (len(rules) == 0 || contains(rules, rule))
+}
+
+// outputFindings writes findings to w in the specified format.
+func outputFindings(w io.Writer, findings []Finding, format string) {
+	switch format {
+	case "plain":
+		style := pathStyle(format)
+		for _, f := range findings {
+			writeQuickfix(w, f.Pos, style, "%s: %s (%s)", f.Severity, f.Message, f.Rule)
+		}
+	case "json":
+		if findings == nil {
+			findings = make([]Finding, 0)
+		}
+		b, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Fprintf(w, "%s\n", b)
+	case "xml":
+		b, err := xml.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Fprintf(w, "%s\n", b)
+	}
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// loadConfig controls how the packages in a path are loaded.
+type loadConfig struct {
+	// FuncBodies type-checks function bodies and records the type of each
+	// expression, selector and used identifier in Package.Info, which
+	// finding usages, call sites and references requires.
+	FuncBodies bool
+	// Comments parses the comments of each file into its ast.File, which
+	// reading directives requires.
+	Comments bool
+
+	// Exclude are glob patterns of files and directories to skip.
+	Exclude []string
+	// Tags are build tags to satisfy in addition to the current GOOS and
+	// GOARCH: files whose build constraints or GOOS and GOARCH suffixes
+	// exclude them are skipped.
+	Tags []string
+	// IncludeVendor searches vendor directories in recursive searches.
+	IncludeVendor bool
+	// SkipGenerated skips files marked as generated code.
+	SkipGenerated bool
+	// Std searches the standard library too. Its packages are
+	// type-checked from source in GOROOT.
+	Std bool
+
+	// Jobs is the maximum number of files parsed, or packages
+	// type-checked, at once. Values less than 1 mean 1.
+	Jobs int
+}
+
+// Package is a parsed and type-checked package.
+type Package struct {
+	Dir string
+	// ImportPath is the path other packages import the package by, derived
+	// from the enclosing module or GOPATH. It is "" if neither is found.
+	ImportPath string
+	// Module is the module of the package, or nil if it is not in one.
+	Module *Module
+	Types  *types.Package
+	Files  []*ast.File
+	Info   *types.Info
+	Fset   *token.FileSet
+
+	name string        // From the package clauses.
+	deps []*Package    // The loaded packages it imports.
+	done chan struct{} // Closed once Types and Info are set.
+}
+
+// getObjects returns an ObjectIdent for each object defined in the packages
+// in the supplied path.
+func getObjects(path string) ([]ObjectIdent, error) {
+	objects, _, err := loadPath(context.Background(), path, loadConfig{})
+	return objects, err
+}
+
+// loadPath parses and type-checks the packages in the supplied path, as
+// loadPaths does.
+func loadPath(ctx context.Context, path string, cfg loadConfig) ([]ObjectIdent, []*Package, error) {
+	var paths []string
+	if path != "" {
+		paths = []string{path}
+	}
+	return loadPaths(ctx, paths, cfg)
+}
+
+// loadPaths parses and type-checks the packages in the supplied paths. It
+// returns the objects found, as getObjects does, and the packages. A file
+// reached from several paths is loaded once.
+//
+// Files are parsed, and packages type-checked, by at most cfg.Jobs
+// goroutines. A package is type-checked after the loaded packages it
+// imports, so that their types are shared. loadPaths stops early and
+// returns ctx.Err() if ctx is done.
+func loadPaths(ctx context.Context, paths []string, cfg loadConfig) ([]ObjectIdent, []*Package, error) {
+	fset := token.NewFileSet()
+	var result []ObjectIdent
+	base := importer.Default()
+	if cfg.Std {
+		// The path's packages must import the same standard library
+		// packages that are searched, or their types would not be
+		// identical.
+		base = importer.ForCompiler(fset, "source", nil)
+	}
+	imp := &lockedImporter{imp: base}
+
+	if cfg.Std {
+		objects, err := loadStd(ctx, imp, fset, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		result = objects
+		if len(paths) == 0 {
+			sortObjects(result)
+			return result, nil, nil
+		}
+	}
+
+	pkgs, err := parsePaths(ctx, paths, fset, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	deps, err := workspaceDeps(ctx, pkgs, fset, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkPackages(ctx, append(pkgs, deps...), imp, cfg); err != nil {
+		return nil, nil, err
+	}
+	for _, pkg := range pkgs {
+		result = append(result, pkg.objects()...)
+	}
+	sortObjects(result)
+	return result, pkgs, nil
+}
+
+// parsePaths parses the directories or files specified by paths and returns
+// their packages, which are not yet type-checked. If a path ends with
+// recursiveSuffix, the directories below it are parsed too. Files and
+// directories skipped by cfg are not parsed, and a file reached from several
+// paths is parsed once.
+func parsePaths(ctx context.Context, paths []string, fset *token.FileSet, cfg loadConfig) ([]*Package, error) {
+	var filenames []string
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		names, err := listFiles(path, cfg)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			abs, err := filepath.Abs(name)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[abs] {
+				seen[abs] = true
+				filenames = append(filenames, name)
+			}
+		}
+	}
+	return parseFiles(ctx, filenames, fset, cfg)
+}
+
+// parseFiles parses filenames and returns their packages, which are not yet
+// type-checked.
+func parseFiles(ctx context.Context, filenames []string, fset *token.FileSet, cfg loadConfig) ([]*Package, error) {
+	files := make([]*ast.File, len(filenames))
+	err := parallel(ctx, cfg.Jobs, len(filenames), func(ctx context.Context, i int) error {
+		var mode parser.Mode
+		if cfg.Comments {
+			mode = parser.ParseComments
+		}
+		f, err := parser.ParseFile(fset, filenames[i], nil, mode)
+		if err != nil {
+			return newParseError(filenames[i], err)
+		}
+		files[i] = f
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Group the files into packages by directory and package name. A
+	// directory may hold a package and its external test package. The
+	// directories are compared as absolute paths, since the paths may
+	// reach a directory by different relative paths.
+	type key struct{ dir, name string }
+	byKey := make(map[key]*Package)
+	var pkgs []*Package
+	for i, f := range files {
+		dir := filepath.Dir(filenames[i])
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		k := key{abs, f.Name.Name}
+		pkg, ok := byKey[k]
+		if !ok {
+			pkg = &Package{Dir: dir, Fset: fset, name: k.name, done: make(chan struct{})}
+			pkg.ImportPath = importPathOf(dir)
+			pkg.Module = moduleOf(dir)
+			byKey[k] = pkg
+			pkgs = append(pkgs, pkg)
+		}
+		pkg.Files = append(pkg.Files, f)
+	}
+	return pkgs, nil
+}
+
+// checkPackages type-checks pkgs in dependency order, using imp for the
+// packages that are not in pkgs.
+func checkPackages(ctx context.Context, pkgs []*Package, imp types.Importer, cfg loadConfig) error {
+	byPath := make(map[string]*Package)
+	for _, pkg := range pkgs {
+		if pkg.ImportPath == "" || strings.HasSuffix(pkg.name, "_test") {
+			continue
+		}
+		if _, ok := byPath[pkg.ImportPath]; !ok {
+			byPath[pkg.ImportPath] = pkg
+		}
+	}
+	for _, pkg := range pkgs {
+		seen := make(map[*Package]bool)
+		for _, f := range pkg.Files {
+			for _, spec := range f.Imports {
+				p, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					continue
+				}
+				if dep, ok := byPath[p]; ok && dep != pkg && !seen[dep] {
+					seen[dep] = true
+					pkg.deps = append(pkg.deps, dep)
+				}
+			}
+		}
+	}
+
+	order := sortDeps(pkgs)
+	return parallel(ctx, cfg.Jobs, len(order), func(ctx context.Context, i int) error {
+		pkg := order[i]
+		for _, dep := range pkg.deps {
+			select {
+			case <-dep.done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return pkg.check(imp, cfg)
+	})
+}
+
+// sortDeps returns pkgs ordered so that each package comes after the
+// packages in its deps. A dependency that would form a cycle, which only
+// test files can create, is removed from deps; such an import is served by
+// the fallback importer instead.
+func sortDeps(pkgs []*Package) []*Package {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[*Package]int)
+	order := make([]*Package, 0, len(pkgs))
+
+	var visit func(pkg *Package)
+	visit = func(pkg *Package) {
+		state[pkg] = visiting
+		deps := pkg.deps[:0]
+		for _, dep := range pkg.deps {
+			switch state[dep] {
+			case visiting:
+				continue // Drop the edge that closes a cycle.
+			case 0:
+				visit(dep)
+			}
+			deps = append(deps, dep)
+		}
+		pkg.deps = deps
+		state[pkg] = visited
+		order = append(order, pkg)
+	}
+	for _, pkg := range pkgs {
+		if state[pkg] == 0 {
+			visit(pkg)
+		}
+	}
+	return order
+}
+
+// check type-checks pkg, whose deps must have been type-checked, and closes
+// pkg.done.
+func (pkg *Package) check(imp types.Importer, cfg loadConfig) error {
+	var errs []error
+	conf := &types.Config{
+		IgnoreFuncBodies:         !cfg.FuncBodies,
+		DisableUnusedImportCheck: true,
+		Importer:                 depsImporter{pkg.deps, imp},
+		// Collect every error, not only the first, for diagnostics.
+		Error: func(err error) { errs = append(errs, err) },
+	}
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+	}
+	if cfg.FuncBodies {
+		info.Uses = make(map[*ast.Ident]types.Object)
+		info.Types = make(map[ast.Expr]types.TypeAndValue)
+		info.Selections = make(map[*ast.SelectorExpr]*types.Selection)
+	}
+
+	tpkg, err := conf.Check(pkg.name, pkg.Fset, pkg.Files, info)
+	if err != nil {
+		// Related: https://github.com/golang/go/issues/9702
+		if len(errs) == 0 {
+			errs = append(errs, err)
+		}
+		return newTypeCheckError(pkg.Dir, errs)
+	}
+	pkg.Types, pkg.Info = tpkg, info
+	close(pkg.done)
+	return nil
+}
+
+// objects returns an ObjectIdent for each object defined in the package.
+func (pkg *Package) objects() []ObjectIdent {
+	var objects []ObjectIdent
+	for ident, obj := range pkg.Info.Defs {
+		if obj == nil || ident.Obj == nil {
+			continue
+		}
+		objects = append(objects, ObjectIdent{obj, ident, pkg.Fset, pkg.Module, pkg.ImportPath})
+	}
+	return objects
+}
+
+// depsImporter imports a package's loaded dependencies, and imports other
+// packages with a fallback importer.
+type depsImporter struct {
+	deps     []*Package
+	fallback types.Importer
+}
+
+func (d depsImporter) Import(path string) (*types.Package, error) {
+	for _, dep := range d.deps {
+		if dep.ImportPath == path {
+			return dep.Types, nil
+		}
+	}
+	return d.fallback.Import(path)
+}
+
+// lockedImporter serializes the calls to an importer that is not safe for
+// concurrent use, so that packages type-checked in parallel can share it.
+type lockedImporter struct {
+	mu  sync.Mutex
+	imp types.Importer
+}
+
+func (l *lockedImporter) Import(path string) (*types.Package, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.imp.Import(path)
+}
+
+// importPathOf returns the import path of the package in dir, from the
+// nearest enclosing go.mod file or GOPATH, or "" if there is neither.
+func importPathOf(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	if mod, modDir := findModule(abs); mod != "" {
+		rel, err := filepath.Rel(modDir, abs)
+		if err != nil {
+			return ""
+		}
+		return path.Join(mod, filepath.ToSlash(rel))
+	}
+	for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
+		src := filepath.Join(gopath, "src") + string(filepath.Separator)
+		if strings.HasPrefix(abs, src) {
+			return filepath.ToSlash(strings.TrimPrefix(abs, src))
+		}
+	}
+	return ""
+}
+
+// findModule returns the module path and directory of the go.mod file in
+// dir or its nearest ancestor, or "" if there is none.
+func findModule(dir string) (mod, modDir string) {
+	for {
+		if mod := modulePath(filepath.Join(dir, "go.mod")); mod != "" {
+			return mod, dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// modulePath returns the path in the module directive of the go.mod file,
+// or "" if the file does not exist or has no module directive.
+func modulePath(gomod string) string {
+	f, err := os.Open(gomod)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "module" {
+			continue
+		}
+		if mod, err := strconv.Unquote(fields[1]); err == nil {
+			return mod
+		}
+		return fields[1]
+	}
+	return ""
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// queryTypeName is the name of the type declared to type-check a query's
+// type expression. It is unexported and unlikely, so that it does not
+// collide with the names of the packages in scope.
+const queryTypeName = "_implQuery"
+
+// methodQuery finds the named types by their methods, as set by the -method
+// and -method-regex flags, rather than by a declared interface.
+type methodQuery struct {
+	// methods are the methods that a type must have, with identical
+	// signatures. They have no receivers.
+	methods []*types.Func
+	// regex, if not nil, must match the name of at least one method of a
+	// type.
+	regex *regexp.Regexp
+	name  string // Describes the query, as the name of its Result.
+}
+
+// newMethodQuery returns the methodQuery for the method signatures sigs,
+// such as "Close() error", and the method name regular expression regex,
+// which is ignored if "". The types in the signatures may refer to the
+// packages of objects, and the packages they import, by name. cmd is the
+// name of the command for the help message.
+func newMethodQuery(cmd string, sigs []string, regex string, objects []ObjectIdent) (methodQuery, error) {
+	var (
+		q     methodQuery
+		parts []string
+	)
+	scope := scopePackages(objects)
+	for _, sig := range sigs {
+		typ, err := evalQueryType("interface{ "+sig+" }", scope)
+		if err != nil {
+			return methodQuery{}, usageErrorf(cmd, "invalid method %q: %v", sig, err)
+		}
+		it := typ.Underlying().(*types.Interface)
+		if it.NumExplicitMethods() != 1 || it.NumEmbeddeds() != 0 {
+			return methodQuery{}, usageErrorf(cmd, "invalid method %q: should be a single method signature, such as 'Close() error'", sig)
+		}
+		m := it.ExplicitMethod(0)
+		if !m.Exported() {
+			// It would belong to the query's package rather than to the
+			// package of any type.
+			return methodQuery{}, usageErrorf(cmd, "invalid method %q: unexported methods cannot be matched by signature; use -method-regex to match them by name", sig)
+		}
+		q.methods = append(q.methods, m)
+		parts = append(parts, strings.TrimSpace(sig))
+	}
+	if regex != "" {
+		rx, err := regexp.Compile(regex)
+		if err != nil {
+			return methodQuery{}, usageErrorf(cmd, "invalid method regex: %v", err)
+		}
+		q.regex = rx
+		parts = append(parts, "matching /"+regex+"/")
+	}
+	q.name = "methods " + joinAnd(parts)
+	return q, nil
+}
+
+// results returns a Result named after the query that lists the named types
+// in idx whose method sets have the methods of q, with the positions of the
+// methods that q matched.
+func (q methodQuery) results(idx *methodIndex, concreteOnly bool) Result {
+	res := Result{
+		Interface:    ResultIdentifier{Name: q.name, Kind: kindInterface},
+		Implementers: make([]ResultIdentifier, 0),
+	}
+	required := types.NewInterfaceType(q.methods, nil).Complete()
+	for _, obj := range idx.candidates(required) {
+		if concreteOnly && types.IsInterface(obj.Type()) {
+			continue
+		}
+		ms := q.match(obj)
+		if ms == nil {
+			continue
+		}
+		iface := newInterfaceObject(nil, queryTypeName, ms)
+		im, ok := implementation(obj, iface)
+		if !ok {
+			continue
+		}
+		ri := im.ResultIdentifier()
+		ri.Methods = explain(obj, iface.Type())
+		res.Implementers = append(res.Implementers, ri)
+	}
+	return res
+}
+
+// match returns the methods of the type declared by obj, or of the pointer
+// to it, that q matches, ordered by name, or nil if the type does not have
+// the methods q requires. The methods must have identical signatures to the
+// methods of q; the regular expression matches the method names.
+func (q methodQuery) match(obj ObjectIdent) []*types.Func {
+	typ := obj.Type()
+	if !types.IsInterface(typ) {
+		typ = types.NewPointer(typ)
+	}
+	mset := types.NewMethodSet(typ)
+	var ms []*types.Func
+	for _, m := range q.methods {
+		sel := mset.Lookup(nil, m.Name())
+		if sel == nil || !types.Identical(sel.Obj().Type(), m.Type()) {
+			return nil
+		}
+		ms = append(ms, sel.Obj().(*types.Func))
+	}
+	if q.regex != nil {
+		found := false
+		for i := 0; i < mset.Len(); i++ {
+			m := mset.At(i).Obj().(*types.Func)
+			if q.regex.MatchString(m.Name()) {
+				found = true
+				if !containsFunc(ms, m) {
+					ms = append(ms, m)
+				}
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Name() < ms[j].Name() })
+	return ms
+}
+
+// containsFunc returns whether fns contains fn.
+func containsFunc(fns []*types.Func, fn *types.Func) bool {
+	for _, f := range fns {
+		if f == fn {
+			return true
+		}
+	}
+	return false
+}
+
+// scopePackages returns the packages that a query's type expression may
+// refer to by name: the packages of objects and the packages they import. If
+// several have the same name, the one with the least path is kept. Packages
+// named main cannot be imported, and the cgo pseudo-package "C" cannot be
+// renamed, so they are left out, as is package unsafe, which the type checker
+// provides itself.
+func scopePackages(objects []ObjectIdent) map[string]*types.Package {
+	scope := make(map[string]*types.Package)
+	add := func(p *types.Package) {
+		if p.Name() == "main" || p.Name() == "_" || p.Path() == "C" || p.Path() == "unsafe" {
+			return
+		}
+		if prev, ok := scope[p.Name()]; !ok || p.Path() < prev.Path() {
+			scope[p.Name()] = p
+		}
+	}
+	seen := make(map[*types.Package]bool)
+	for _, o := range objects {
+		p := o.Pkg()
+		if p == nil || seen[p] {
+			continue
+		}
+		seen[p] = true
+		add(p)
+		for _, imp := range p.Imports() {
+			add(imp)
+		}
+	}
+	return scope
+}
+
+// interfaceLiteralRx matches the start of an interface type literal.
+var interfaceLiteralRx = regexp.MustCompile(`^\s*interface\s*{`)
+
+// isInterfaceLiteral reports whether name, the value of an -interface flag,
+// is an interface type literal, such as "interface{ Close() error }", rather
+// than packageName.interfaceName.
+func isInterfaceLiteral(name string) bool {
+	return interfaceLiteralRx.MatchString(name)
+}
+
+// findInterfaces returns the interfaces that name refers to: the interface
+// types in objects whose packageName.interfaceName is name, or, if name is
+// an interface literal, a new interface with the literal's methods. The
+// literal may refer to the packages of objects, and the packages they
+// import, by name. cmd is the name of the command for the help message.
+func findInterfaces(cmd string, objects []ObjectIdent, name string) ([]ObjectIdent, error) {
+	if !isInterfaceLiteral(name) {
+		interfaces := filterInterfaces(objects, name)
+		if len(interfaces) == 0 {
+			return nil, &InterfaceNotFoundError{Name: name}
+		}
+		return interfaces, nil
+	}
+	typ, err := evalQueryType(name, scopePackages(objects))
+	if err != nil {
+		return nil, usageErrorf(cmd, "invalid interface %q: %v", name, err)
+	}
+	it, ok := typ.Underlying().(*types.Interface)
+	if !ok || !it.IsMethodSet() {
+		return nil, usageErrorf(cmd, "invalid interface %q: should be an interface literal with only methods and embedded interfaces", name)
+	}
+	query := typ.(*types.Named).Obj().Pkg()
+	var ms []*types.Func
+	for i := 0; i < it.NumMethods(); i++ {
+		m := it.Method(i)
+		if !m.Exported() && m.Pkg() == query {
+			// No type outside the query's package could implement it;
+			// the unexported methods of embedded interfaces are fine.
+			return nil, usageErrorf(cmd, "invalid interface %q: method %s is unexported, so no type can implement it", name, m.Name())
+		}
+		ms = append(ms, m)
+	}
+	literal := types.TypeString(it, func(p *types.Package) string { return p.Name() })
+	return []ObjectIdent{newInterfaceObject(nil, literal, ms)}, nil
+}
+
+// evalQueryType type-checks the type expression src, which may refer to the
+// packages in scope by name, and returns its type.
+func evalQueryType(src string, scope map[string]*types.Package) (types.Type, error) {
+	names := make([]string, 0, len(scope))
+	byPath := make(map[string]*types.Package)
+	for name, p := range scope {
+		names = append(names, name)
+		byPath[p.Path()] = p
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("package query\n\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "import %s %q\n", name, scope[name].Path())
+	}
+	fmt.Fprintf(&b, "\ntype %s %s\n", queryTypeName, src)
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", b.String(), 0)
+	if err != nil {
+		var list scanner.ErrorList
+		if errors.As(err, &list) && len(list) != 0 {
+			return nil, errors.New(list[0].Msg)
+		}
+		return nil, err
+	}
+	var first error
+	conf := types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if p, ok := byPath[path]; ok {
+				return p, nil
+			}
+			return nil, fmt.Errorf("package %s not in scope", path)
+		}),
+		Error: func(err error) {
+			// Imports that the expression does not use are soft errors.
+			if te, ok := err.(types.Error); ok && te.Soft {
+				return
+			}
+			if first == nil {
+				first = err
+				if te, ok := err.(types.Error); ok {
+					first = errors.New(te.Msg)
+				}
+			}
+		},
+	}
+	pkg, _ := conf.Check("query", fset, []*ast.File{f}, nil)
+	if first != nil {
+		return nil, first
+	}
+	return pkg.Scope().Lookup(queryTypeName).Type(), nil
+}
+
+// importerFunc is a types.Importer that calls the function.
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+)
+
+// NearMiss is a named type that has at least half of an interface's methods
+// by name, but does not implement the interface.
+type NearMiss struct {
+	ResultIdentifier
+	// Missing are the interface's methods that the type does not have.
+	Missing []string
+	// WrongSignature describes the methods that the type has with a
+	// different signature than the interface's.
+	WrongSignature []string `json:",omitempty"`
+
+	obj ObjectIdent
+}
+
+// nearMisses returns the near misses of iface among the types in idx,
+// closest first: ordered by the number of methods they lack or get wrong,
+// then by the order of idx.
+func nearMisses(idx *methodIndex, iface ObjectIdent) []NearMiss {
+	it := iface.Type().Underlying().(*types.Interface)
+	n := it.NumMethods()
+	if n == 0 {
+		return nil
+	}
+
+	// Count the interface's methods that each type has by name.
+	hits := make(map[int]int)
+	for i := 0; i < n; i++ {
+		for _, t := range idx.byMethod[it.Method(i).Id()] {
+			hits[t]++
+		}
+	}
+
+	var misses []NearMiss
+	for t := range idx.types {
+		if 2*hits[t] < n {
+			continue
+		}
+		obj := idx.types[t]
+		if NewChar(obj) == NewChar(iface) || types.Implements(types.NewPointer(obj.Type()), it) || types.Implements(obj.Type(), it) {
+			continue
+		}
+		nm := NearMiss{ResultIdentifier: NewResultIdentifier(obj), obj: obj}
+		for i := 0; i < n; i++ {
+			m := it.Method(i)
+			sel := idx.sets[t].Lookup(m.Pkg(), m.Name())
+			switch {
+			case sel == nil:
+				nm.Missing = append(nm.Missing, m.Name())
+			case !types.Identical(sel.Obj().Type(), m.Type()):
+				nm.WrongSignature = append(nm.WrongSignature, fmt.Sprintf("%s: have %s, want %s",
+					m.Name(), types.TypeString(sel.Obj().Type(), nil), types.TypeString(m.Type(), nil)))
+			}
+		}
+		if len(nm.Missing) == 0 && len(nm.WrongSignature) == 0 {
+			// Nothing to report.
+			continue
+		}
+		misses = append(misses, nm)
+	}
+	sort.SliceStable(misses, func(i, j int) bool {
+		return len(misses[i].Missing)+len(misses[i].WrongSignature) < len(misses[j].Missing)+len(misses[j].WrongSignature)
+	})
+	return misses
+}
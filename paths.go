@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pathStyles are the supported values of the -paths flag.
+var pathStyles = []string{"base", "rel", "abs"}
+
+// addPathsFlag registers the -paths flag in fs.
+func addPathsFlag(fs *flag.FlagSet) {
+	fs.StringVar(&arg.Paths, "paths", "", "how file paths are printed in plain and quickfix output, should be one of: {"+strings.Join(pathStyles, ",")+"}; the default is base for plain and rel for quickfix")
+}
+
+// checkPathsFlag checks the -paths flag. cmd is the name of the command for
+// the help message.
+func checkPathsFlag(cmd string) error {
+	if arg.Paths != "" && !contains(pathStyles, arg.Paths) {
+		return usageErrorf(cmd, "path style should be one of: {%s}", strings.Join(pathStyles, ","))
+	}
+	return nil
+}
+
+// pathStyle returns the path style for output in format: the -paths flag, or
+// the default of the format.
+func pathStyle(format string) string {
+	switch {
+	case arg.Paths != "":
+		return arg.Paths
+	case format == "quickfix":
+		return "rel"
+	default:
+		return "base"
+	}
+}
+
+// displayPos returns pos as "file:line:column", with the file name printed
+// in style: "base" for the base name only, "abs" for the absolute path, and
+// "rel" for the path relative to the working directory. Paths that cannot be
+// made relative are printed absolute.
+func displayPos(pos token.Position, style string) string {
+	if pos.Filename != "" {
+		pos.Filename = displayPath(pos.Filename, style)
+	}
+	return pos.String()
+}
+
+func displayPath(name, style string) string {
+	if style == "base" {
+		return filepath.Base(name)
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return name
+	}
+	if style == "abs" {
+		return abs
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return abs
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil {
+		return abs
+	}
+	return rel
+}
+
+// writeQuickfix writes a line in the "file:line:column: message" format
+// that editors read as a list of locations, such as Vim's quickfix list.
+func writeQuickfix(w io.Writer, pos token.Position, style, format string, a ...interface{}) {
+	fmt.Fprintf(w, "%s: %s\n", displayPos(pos, style), fmt.Sprintf(format, a...))
+}
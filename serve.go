@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const serveUsage = `Serve implementer queries over HTTP, as JSON.
+
+Usage:
+  impl serve -path <path> [-addr localhost:7777] [flags]
+
+The path is loaded once and kept in memory. It is reloaded when a file in it
+changes, which is checked every -poll interval.
+
+Endpoints:
+  /implementers?interface=pkg.Name  the Results of the interface, as impl -format json
+  /interfaces-of?type=pkg.Name      a Result for each interface the type implements
+  /near-miss?interface=pkg.Name     the types that almost implement the interface
+  /graph                            the implementation graph, as impl graph
+
+/implementers and /graph take the concrete-only, sort and group-by parameters,
+like the flags. Errors are returned as -diagnostics json does.
+
+Flags:`
+
+func serveMain(ctx context.Context, args []string) {
+	var (
+		addr string
+		poll time.Duration
+	)
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, serveUsage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&addr, "addr", "localhost:7777", "address to listen on")
+	addPathFlags(fs)
+	fs.DurationVar(&poll, "poll", 2*time.Second, "how often to check the path for changed files; 0 disables reloading")
+	addDiagnosticsFlag(fs)
+	addLoadFlags(fs)
+	parseFlags(fs, "impl serve", args)
+
+	if err := applyConfig(fs, "impl serve"); err != nil {
+		fatal(err)
+	}
+	if err := checkServeFlags(); err != nil {
+		fatal(err)
+	}
+
+	s := &server{paths: arg.Path, cfg: argLoadConfig()}
+	if _, err := s.refresh(ctx); err != nil {
+		fatal(err)
+	}
+	if poll > 0 {
+		go s.watch(ctx, poll)
+	}
+
+	srv := &http.Server{Addr: addr, Handler: s.handler()}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	logger.Printf("serving %s on http://%s", strings.Join(arg.Path, ", "), addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		fatal(err)
+	}
+}
+
+func checkServeFlags() error {
+	const cmd = "impl serve"
+	if err := resolveScope(cmd); err != nil {
+		return err
+	}
+	if len(arg.Path) == 0 && !arg.Std {
+		return usageErrorf(cmd, "must specify directory to search (-path flag) or -std.")
+	}
+	return checkDiagnosticsFlag(cmd)
+}
+
+// server answers queries from a snapshot of the loaded path, which it
+// replaces when the path's files change.
+type server struct {
+	paths []string
+	cfg   loadConfig
+
+	mu    sync.RWMutex // Guards snap.
+	snap  *snapshot
+	stamp string // The stamp of the files snap was loaded from.
+}
+
+// snapshot is the loaded path. It is not modified once loaded.
+type snapshot struct {
+	objects    []ObjectIdent
+	idx        *methodIndex
+	interfaces []ObjectIdent // The declared interfaces.
+}
+
+// current returns the current snapshot.
+func (s *server) current() *snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snap
+}
+
+// refresh reloads the path if its files changed since the last load, and
+// reports whether it did.
+func (s *server) refresh(ctx context.Context) (bool, error) {
+	stamp, err := filesStamp(s.paths, s.cfg)
+	if err != nil {
+		return false, err
+	}
+	s.mu.RLock()
+	same := s.snap != nil && stamp == s.stamp
+	s.mu.RUnlock()
+	if same {
+		return false, nil
+	}
+
+	objects, _, err := loadPaths(ctx, s.paths, s.cfg)
+	if err != nil {
+		return false, err
+	}
+	snap := &snapshot{objects: objects, idx: newMethodIndex(objects), interfaces: declaredInterfaces(objects)}
+	s.mu.Lock()
+	s.snap, s.stamp = snap, stamp
+	s.mu.Unlock()
+	return true, nil
+}
+
+// watch refreshes s every interval until ctx is done. Failed reloads are
+// logged, and the previous snapshot is kept.
+func (s *server) watch(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			reloaded, err := s.refresh(ctx)
+			switch {
+			case err != nil && ctx.Err() == nil:
+				logger.Printf("reload failed, serving the previous load: %v", err)
+			case reloaded:
+				logger.Printf("reloaded %s", strings.Join(s.paths, ", "))
+			}
+		}
+	}
+}
+
+// filesStamp returns a digest of the names, sizes and modification times of
+// the files that loading paths with cfg parses, which changes when one of
+// them is edited, added or removed.
+func filesStamp(paths []string, cfg loadConfig) (string, error) {
+	var files []string
+	for _, path := range paths {
+		names, err := listFiles(path, cfg)
+		if err != nil {
+			return "", err
+		}
+		files = append(files, names...)
+	}
+	h := sha256.New()
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", f, info.Size(), info.ModTime().UnixNano())
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/implementers", s.implementers)
+	mux.HandleFunc("/interfaces-of", s.interfacesOf)
+	mux.HandleFunc("/near-miss", s.nearMiss)
+	mux.HandleFunc("/graph", s.graph)
+	return mux
+}
+
+func (s *server) implementers(w http.ResponseWriter, r *http.Request) {
+	q, err := parseQuery(r, "interface")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	snap := s.current()
+	interfaces, err := findInterfaces("impl serve", snap.objects, q.name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	results := implementersOf(snap.idx, interfaces, q.concreteOnly)
+	sortResults(results, q.sort, q.groupBy)
+	writeJSON(w, results)
+}
+
+func (s *server) interfacesOf(w http.ResponseWriter, r *http.Request) {
+	q, err := parseQuery(r, "type")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	snap := s.current()
+	var typ *ObjectIdent
+	for i, o := range snap.idx.types {
+		if ri := NewResultIdentifier(o); ri.Name == q.name {
+			typ = &snap.idx.types[i]
+			break
+		}
+	}
+	if typ == nil {
+		writeError(w, &TypeNotFoundError{Name: q.name})
+		return
+	}
+	// Check the type alone against each interface.
+	idx := &methodIndex{types: []ObjectIdent{*typ}}
+	results := implementersOf(idx, snap.interfaces, false)
+	found := make([]Result, 0)
+	for _, r := range results {
+		if len(r.Implementers) != 0 {
+			found = append(found, r)
+		}
+	}
+	sortResults(found, q.sort, q.groupBy)
+	writeJSON(w, found)
+}
+
+func (s *server) nearMiss(w http.ResponseWriter, r *http.Request) {
+	q, err := parseQuery(r, "interface")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	snap := s.current()
+	interfaces, err := findInterfaces("impl serve", snap.objects, q.name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	misses := nearMisses(snap.idx, interfaces[0])
+	if misses == nil {
+		misses = make([]NearMiss, 0)
+	}
+	writeJSON(w, misses)
+}
+
+func (s *server) graph(w http.ResponseWriter, r *http.Request) {
+	q, err := parseQuery(r, "")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	snap := s.current()
+	g := buildGraph(snap.idx, snap.interfaces, q.concreteOnly)
+	sortGraph(&g, q.sort, q.groupBy)
+	writeJSON(w, g)
+}
+
+// query is the parameters of a request.
+type query struct {
+	name         string // The interface or type, if the endpoint takes one.
+	concreteOnly bool
+	sort         string
+	groupBy      string
+}
+
+// parseQuery returns the parameters of r. nameParam is the parameter that
+// names an interface or type, which is required, or "" if there is none.
+func parseQuery(r *http.Request, nameParam string) (query, error) {
+	const cmd = "impl serve"
+	v := r.URL.Query()
+	q := query{sort: sortKeys[0], groupBy: v.Get("group-by")}
+	if nameParam != "" {
+		q.name = v.Get(nameParam)
+		if q.name == "" {
+			return q, usageErrorf(cmd, "must specify the %s parameter in format: packageName.Name", nameParam)
+		}
+	}
+	if s := v.Get("sort"); s != "" {
+		q.sort = s
+	}
+	if s := v.Get("concrete-only"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return q, usageErrorf(cmd, "invalid concrete-only parameter: %v", err)
+		}
+		q.concreteOnly = b
+	}
+	switch {
+	case !contains(sortKeys, q.sort):
+		return q, usageErrorf(cmd, "sort order should be one of: {%s}", strings.Join(sortKeys, ","))
+	case q.groupBy != "" && !contains(groupKeys, q.groupBy):
+		return q, usageErrorf(cmd, "grouping should be one of: {%s}", strings.Join(groupKeys, ","))
+	}
+	return q, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		logger.Printf("failed to write response: %v", err)
+	}
+}
+
+// writeError writes err as a Diagnostic, with the HTTP status for its kind.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrUsage):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrInterfaceNotFound), errors.Is(err, ErrTypeNotFound):
+		status = http.StatusNotFound
+	}
+	writeErrorStatus(w, status, err)
+}
+
+// writeErrorStatus writes err as a Diagnostic with the HTTP status.
+func writeErrorStatus(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(newDiagnostic(err))
+}
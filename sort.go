@@ -0,0 +1,149 @@
+package main
+
+import (
+	"go/token"
+	"sort"
+)
+
+// sortKeys are the supported values of the -sort flag. The first is the
+// default.
+var sortKeys = []string{"package", "position", "name", "kind"}
+
+// groupKeys are the supported values of the -group-by flag.
+var groupKeys = []string{"package", "file"}
+
+// sortItem is the view of a ResultIdentifier or Node that is sorted.
+type sortItem struct {
+	Name    string
+	Kind    string
+	Package string
+	Pos     token.Position
+}
+
+// less reports whether a sorts before b when sorting by key. Ties are broken
+// by package, then position, then name, so that the order is total.
+func less(a, b sortItem, key string) bool {
+	switch key {
+	case "name":
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+	case "kind":
+		if a.Kind != b.Kind {
+			return a.Kind == kindInterface
+		}
+	case "position":
+		if c := comparePos(a.Pos, b.Pos); c != 0 {
+			return c < 0
+		}
+	}
+	if a.Package != b.Package {
+		return a.Package < b.Package
+	}
+	if c := comparePos(a.Pos, b.Pos); c != 0 {
+		return c < 0
+	}
+	return a.Name < b.Name
+}
+
+// comparePos compares positions by filename, then line, then column.
+func comparePos(a, b token.Position) int {
+	switch {
+	case a.Filename != b.Filename:
+		if a.Filename < b.Filename {
+			return -1
+		}
+		return 1
+	case a.Line != b.Line:
+		return a.Line - b.Line
+	default:
+		return a.Column - b.Column
+	}
+}
+
+// groupOf returns the key of the group that item belongs to when grouping by
+// group, or "" if group is "".
+func groupOf(item sortItem, group string) string {
+	switch group {
+	case "package":
+		return item.Package
+	case "file":
+		return item.Pos.Filename
+	}
+	return ""
+}
+
+// sortObjects orders objects by position, so that the objects of a load do
+// not depend on the order of map iteration or of parallel parsing.
+func sortObjects(objects []ObjectIdent) {
+	sort.SliceStable(objects, func(i, j int) bool {
+		return comparePos(objects[i].FileSet.Position(objects[i].Pos()), objects[j].FileSet.Position(objects[j].Pos())) < 0
+	})
+}
+
+func (ri ResultIdentifier) sortItem() sortItem {
+	return sortItem{ri.Name, ri.Kind, ri.Package, ri.Pos}
+}
+
+// sortResults orders res, and the implementers in each Result, by key. If
+// group is not "", the implementers are grouped by it first and their Group
+// is set.
+func sortResults(res []Result, key, group string) {
+	sort.SliceStable(res, func(i, j int) bool {
+		return less(res[i].Interface.sortItem(), res[j].Interface.sortItem(), key)
+	})
+	for _, r := range res {
+		ims := r.Implementers
+		for i := range ims {
+			ims[i].Group = groupOf(ims[i].sortItem(), group)
+		}
+		sort.SliceStable(ims, func(i, j int) bool {
+			if ims[i].Group != ims[j].Group {
+				return ims[i].Group < ims[j].Group
+			}
+			return less(ims[i].sortItem(), ims[j].sortItem(), key)
+		})
+		sort.SliceStable(r.Usages, func(i, j int) bool {
+			return comparePos(r.Usages[i].Pos, r.Usages[j].Pos) < 0
+		})
+	}
+}
+
+func (n Node) sortItem() sortItem {
+	return sortItem{n.Name, n.Kind, n.Package, n.Pos}
+}
+
+// sortGraph orders the nodes of g by key, renumbering them, and orders the
+// edges by their nodes. If group is not "", the nodes are grouped by it first
+// and their Group is set.
+func sortGraph(g *Graph, key, group string) {
+	for i := range g.Nodes {
+		g.Nodes[i].Group = groupOf(g.Nodes[i].sortItem(), group)
+	}
+	nodes := g.Nodes
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Group != nodes[j].Group {
+			return nodes[i].Group < nodes[j].Group
+		}
+		return less(nodes[i].sortItem(), nodes[j].sortItem(), key)
+	})
+
+	ids := make(map[int]int, len(nodes)) // Old ID to new ID.
+	for i := range nodes {
+		ids[nodes[i].ID] = i
+		nodes[i].ID = i
+	}
+	for i := range g.Edges {
+		g.Edges[i].From, g.Edges[i].To = ids[g.Edges[i].From], ids[g.Edges[i].To]
+	}
+	sort.SliceStable(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Kind < b.Kind
+	})
+}
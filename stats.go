@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const statsUsage = `Report statistics about the interfaces in the go source code.
+
+For each interface: its number of methods, its concrete and interface
+implementers, and the packages the implementers are in. For each package: the
+number of interfaces it defines, and the number of interfaces that its
+concrete types implement. Then totals for the path.
+
+Example:
+  impl stats -path ./luci/gae/service/... -sort methods
+
+Flags:`
+
+// statsSortKeys are the supported values of the -sort flag of impl stats.
+// The first is the default.
+var statsSortKeys = []string{"methods", "implementers", "name"}
+
+// Stats is the report of impl stats.
+type Stats struct {
+	Interfaces []InterfaceStats
+	// Packages are ranked by the number of interfaces they define and
+	// implement, most first.
+	Packages []PackageStats
+	Totals   StatsTotals
+}
+
+// InterfaceStats describes an interface and its implementers.
+type InterfaceStats struct {
+	ResultIdentifier
+	Methods int
+	// Concrete and Interfaces are the numbers of concrete and interface
+	// types that implement the interface.
+	Concrete   int
+	Interfaces int
+	// ImplementerPackages are the packages of the implementers, sorted.
+	ImplementerPackages []string `json:",omitempty" xml:",omitempty"`
+}
+
+// PackageStats describes the interfaces a package defines and implements.
+type PackageStats struct {
+	Package string
+	// Defines is the number of interfaces declared in the package.
+	Defines int
+	// Implements is the number of distinct interfaces implemented by at
+	// least one concrete type declared in the package.
+	Implements int
+}
+
+// StatsTotals are the totals of a Stats.
+type StatsTotals struct {
+	Interfaces int
+	// Unimplemented is the number of interfaces with no concrete
+	// implementer.
+	Unimplemented int
+	Concrete      int // The number of concrete named types.
+	Packages      int
+	// Implementations is the number of pairs of a concrete type and an
+	// interface it implements.
+	Implementations int
+}
+
+func statsMain(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, statsUsage)
+		fs.PrintDefaults()
+	}
+	addPathFlags(fs)
+	fs.StringVar(&arg.Format, "format", "plain", "output format, should be one of: {plain,json,xml}")
+	fs.StringVar(&arg.Sort, "sort", statsSortKeys[0], "order of the interfaces, most first, should be one of: {"+strings.Join(statsSortKeys, ",")+"}")
+	addPathsFlag(fs)
+	addDiagnosticsFlag(fs)
+	addLoadFlags(fs)
+	parseFlags(fs, "impl stats", args)
+
+	if err := applyConfig(fs, "impl stats"); err != nil {
+		fatal(err)
+	}
+	if err := checkStatsFlags(); err != nil {
+		fatal(err)
+	}
+
+	objects, _, err := loadPaths(ctx, arg.Path, argLoadConfig())
+	if err != nil {
+		fatal(err)
+	}
+	st := buildStats(newMethodIndex(objects), declaredInterfaces(objects), arg.Sort)
+	outputStats(os.Stdout, st, arg.Format)
+	if len(st.Interfaces) == 0 {
+		os.Exit(exitNoneFound)
+	}
+}
+
+func checkStatsFlags() error {
+	const cmd = "impl stats"
+	if err := resolveScope(cmd); err != nil {
+		return err
+	}
+	switch {
+	case len(arg.Path) == 0 && !arg.Std:
+		return usageErrorf(cmd, "must specify directory to search (-path flag) or -std.")
+	case !contains([]string{"plain", "json", "xml"}, arg.Format):
+		return usageErrorf(cmd, "output format should be one of: {plain,json,xml}")
+	case !contains(statsSortKeys, arg.Sort):
+		return usageErrorf(cmd, "sort order should be one of: {%s}", strings.Join(statsSortKeys, ","))
+	}
+	if err := checkPathsFlag(cmd); err != nil {
+		return err
+	}
+	return checkDiagnosticsFlag(cmd)
+}
+
+// buildStats returns the Stats of the distinct interfaces in interfaces and
+// the types in idx, with the interfaces ordered by key.
+func buildStats(idx *methodIndex, interfaces []ObjectIdent, key string) Stats {
+	var st Stats
+	pkgs := make(map[string]*PackageStats)
+	pkg := func(path string) *PackageStats {
+		p, ok := pkgs[path]
+		if !ok {
+			p = &PackageStats{Package: path}
+			pkgs[path] = p
+		}
+		return p
+	}
+	for _, o := range idx.types {
+		if path := objectPackage(o); path != "" {
+			pkg(path)
+		}
+		if !types.IsInterface(o.Type()) {
+			st.Totals.Concrete++
+		}
+	}
+
+	eachImplementer(idx, interfaces, false, func(iface ObjectIdent, implementers []Implementer) {
+		is := InterfaceStats{
+			ResultIdentifier: NewResultIdentifier(iface),
+			Methods:          iface.Type().Underlying().(*types.Interface).NumMethods(),
+		}
+		if path := objectPackage(iface); path != "" {
+			pkg(path).Defines++
+		}
+		implementing := make(map[string]bool) // The packages of concrete implementers.
+		for _, im := range implementers {
+			if types.IsInterface(im.Type()) {
+				is.Interfaces++
+			} else {
+				is.Concrete++
+			}
+			path := objectPackage(im.ObjectIdent)
+			if path == "" {
+				continue
+			}
+			if !contains(is.ImplementerPackages, path) {
+				is.ImplementerPackages = append(is.ImplementerPackages, path)
+			}
+			if !types.IsInterface(im.Type()) && !implementing[path] {
+				implementing[path] = true
+				pkg(path).Implements++
+			}
+		}
+		sort.Strings(is.ImplementerPackages)
+		if is.Concrete == 0 {
+			st.Totals.Unimplemented++
+		}
+		st.Totals.Implementations += is.Concrete
+		st.Interfaces = append(st.Interfaces, is)
+	})
+	st.Totals.Interfaces = len(st.Interfaces)
+
+	sort.SliceStable(st.Interfaces, func(i, j int) bool {
+		a, b := st.Interfaces[i], st.Interfaces[j]
+		switch key {
+		case "methods":
+			if a.Methods != b.Methods {
+				return a.Methods > b.Methods
+			}
+		case "implementers":
+			if a.Concrete+a.Interfaces != b.Concrete+b.Interfaces {
+				return a.Concrete+a.Interfaces > b.Concrete+b.Interfaces
+			}
+		}
+		return less(a.sortItem(), b.sortItem(), "name")
+	})
+
+	for _, p := range pkgs {
+		st.Packages = append(st.Packages, *p)
+	}
+	sort.Slice(st.Packages, func(i, j int) bool {
+		a, b := st.Packages[i], st.Packages[j]
+		if a.Defines+a.Implements != b.Defines+b.Implements {
+			return a.Defines+a.Implements > b.Defines+b.Implements
+		}
+		return a.Package < b.Package
+	})
+	st.Totals.Packages = len(st.Packages)
+	return st
+}
+
+// outputStats writes st to w in the specified format.
+func outputStats(w io.Writer, st Stats, format string) {
+	switch format {
+	case "plain":
+		style := pathStyle(format)
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "METHODS\tCONCRETE\tINTERFACES\tINTERFACE\tPOSITION\tIMPLEMENTED IN")
+		for _, is := range st.Interfaces {
+			fmt.Fprintf(tw, "%d\t%d\t%d\t%s\t%s\t%s\n", is.Methods, is.Concrete, is.Interfaces,
+				is.Name, displayPos(is.Pos, style), strings.Join(is.ImplementerPackages, ", "))
+		}
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "DEFINES\tIMPLEMENTS\tPACKAGE")
+		for _, p := range st.Packages {
+			fmt.Fprintf(tw, "%d\t%d\t%s\n", p.Defines, p.Implements, p.Package)
+		}
+		tw.Flush()
+		t := st.Totals
+		fmt.Fprintf(w, "\n%d interfaces (%d with no concrete implementer), %d concrete types, %d packages, %d implementations\n",
+			t.Interfaces, t.Unimplemented, t.Concrete, t.Packages, t.Implementations)
+	case "json":
+		b, err := json.MarshalIndent(st, "", "  ")
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Fprintf(w, "%s\n", b)
+	case "xml":
+		b, err := xml.MarshalIndent(st, "", "  ")
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Fprintf(w, "%s\n", b)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"go/build"
+	"go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stdScope is the -scope element that stands for the standard library.
+const stdScope = "std"
+
+// resolveScope sets arg.Path and arg.Std from the -path, -scope and
+// -paths-from flags. -scope is a comma-separated list of stdScope and paths,
+// and -paths-from names a file, or "-" for stdin, with a path on each line.
+// Each path is kept once. cmd is the name of the command for the help
+// message.
+func resolveScope(cmd string) error {
+	paths := append([]string(nil), arg.Path...)
+	for _, s := range arg.Scope {
+		if s == stdScope {
+			arg.Std = true
+			continue
+		}
+		paths = append(paths, s)
+	}
+	if arg.PathsFrom != "" {
+		from, err := readPaths(arg.PathsFrom, argLoadConfig())
+		if err != nil {
+			return usageErrorf(cmd, "failed to read the paths to search (-paths-from flag): %v", err)
+		}
+		paths = append(paths, from...)
+		// Stdin can only be read once.
+		arg.PathsFrom = ""
+	}
+	arg.Path = uniquePaths(paths)
+	return nil
+}
+
+// readPaths returns the paths listed in the file name, or in stdin if name
+// is "-", one on each line. Blank lines, files that are not go source files,
+// and files that cfg skips are skipped, so that the output of git ls-files
+// can be read.
+func readPaths(name string, cfg loadConfig) ([]string, error) {
+	r := io.Reader(os.Stdin)
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var paths []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		p := strings.TrimSpace(sc.Text())
+		if p == "" {
+			continue
+		}
+		if info, err := os.Stat(p); err == nil && !info.IsDir() && (filepath.Ext(p) != ".go" || cfg.skipListed(p)) {
+			continue
+		}
+		paths = append(paths, p)
+	}
+	return paths, sc.Err()
+}
+
+// uniquePaths returns paths without the paths that are the same as an
+// earlier one once cleaned.
+func uniquePaths(paths []string) []string {
+	var unique []string
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		c := filepath.Clean(p)
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		unique = append(unique, p)
+	}
+	return unique
+}
+
+// stdPackages returns the import paths of the packages in GOROOT. Like the
+// go tool's std pattern, it does not include the packages in cmd. testdata,
+// vendor, and the directories excluded by cfg are skipped as in a recursive
+// search. Since the importer parses whole packages, file exclusions and
+// SkipGenerated do not apply to the standard library.
+func stdPackages(cfg loadConfig) ([]string, error) {
+	src := filepath.Join(build.Default.GOROOT, "src")
+	var paths []string
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || p == src {
+			return nil
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		// builtin only documents the predeclared identifiers and does
+		// not type-check.
+		if rel == "cmd" || rel == "builtin" || cfg.skipDir(src, p) {
+			return filepath.SkipDir
+		}
+		paths = append(paths, rel)
+		return nil
+	})
+	return paths, err
+}
+
+// loadStd type-checks the standard library from source using imp, which
+// must be a source importer that records positions in fset. The packages are
+// imported one at a time, since imp serializes the imports anyway. It returns an
+// ObjectIdent for each package-level type. The returned objects have no
+// Ident, since the importer does not keep the syntax trees.
+func loadStd(ctx context.Context, imp types.Importer, fset *token.FileSet, cfg loadConfig) ([]ObjectIdent, error) {
+	paths, err := stdPackages(cfg)
+	if err != nil {
+		return nil, wrapErr("failed to list standard library packages", err)
+	}
+
+	var objects []ObjectIdent
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		pkg, err := imp.Import(path)
+		if err != nil {
+			var noGo *build.NoGoError
+			if errors.As(err, &noGo) {
+				// A directory that only holds other packages.
+				continue
+			}
+			return nil, wrapErr("failed to type-check standard library package "+path, err)
+		}
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			if obj, ok := scope.Lookup(name).(*types.TypeName); ok {
+				objects = append(objects, ObjectIdent{obj, nil, fset, nil, path})
+			}
+		}
+	}
+	return objects, nil
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+// terminal is not supported on this platform.
+type terminal struct{}
+
+func openTerminal() (*terminal, error) {
+	return nil, errors.New("impl browse is not supported on this platform")
+}
+
+func (t *terminal) raw() error             { return nil }
+func (t *terminal) restore()               {}
+func (t *terminal) size() (rows, cols int) { return 24, 80 }
@@ -0,0 +1,88 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// terminal is the controlling terminal in raw mode, showing the alternate
+// screen. The terminal settings are changed with stty.
+type terminal struct {
+	saved string // The settings to restore, from stty -g.
+	// rows and cols are the size of the terminal, read again when winch
+	// receives a SIGWINCH rather than on every redraw.
+	rows, cols int
+	winch      chan os.Signal
+}
+
+// openTerminal puts the terminal in raw mode and switches to the alternate
+// screen. It fails if stdin is not a terminal.
+func openTerminal() (*terminal, error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("stdin is not a terminal: %v", err)
+	}
+	t := &terminal{saved: strings.TrimSpace(saved), winch: make(chan os.Signal, 1)}
+	if err := t.raw(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// raw puts the terminal in raw mode and switches to the alternate screen.
+// The size is read, since it may have changed while the terminal was
+// restored, and then read again on resizes.
+func (t *terminal) raw() error {
+	if _, err := stty("raw", "-echo", "-iexten"); err != nil {
+		return err
+	}
+	t.rows, t.cols = readSize()
+	signal.Notify(t.winch, syscall.SIGWINCH)
+	// Switch to the alternate screen and hide the cursor.
+	fmt.Fprint(os.Stdout, "\x1b[?1049h\x1b[?25l")
+	return nil
+}
+
+// restore switches back to the normal screen and restores the terminal
+// settings.
+func (t *terminal) restore() {
+	signal.Stop(t.winch)
+	fmt.Fprint(os.Stdout, "\x1b[?25h\x1b[?1049l")
+	stty(t.saved)
+}
+
+// size returns the number of rows and columns of the terminal.
+func (t *terminal) size() (rows, cols int) {
+	select {
+	case <-t.winch:
+		t.rows, t.cols = readSize()
+	default:
+	}
+	return t.rows, t.cols
+}
+
+// readSize returns the number of rows and columns of the terminal, from
+// stty, or 24 and 80 if they are unknown.
+func readSize() (rows, cols int) {
+	out, err := stty("size")
+	if err == nil {
+		fmt.Sscan(out, &rows, &cols)
+	}
+	if rows <= 0 || cols <= 0 {
+		return 24, 80
+	}
+	return rows, cols
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// Kinds of Usage.
+const (
+	usageAssignment = "assignment"
+	usageArgument   = "argument"
+	usageReturn     = "return"
+	usageComposite  = "composite literal"
+	usageConversion = "conversion"
+)
+
+// Usage is a place where a value of a concrete type becomes an interface
+// value of the target interface.
+type Usage struct {
+	Kind string // One of the usage* constants.
+	Type string // The concrete type of the converted value.
+	Pos  token.Position
+	End  token.Position
+}
+
+// findUsages returns the places in pkgs where a value of a concrete type is
+// converted to iface: assignments, call arguments, returns, composite
+// literal elements, and explicit conversions. The packages must have been
+// loaded with loadConfig.FuncBodies.
+func findUsages(pkgs []*Package, iface types.Type) []Usage {
+	var usages []Usage
+	for _, pkg := range pkgs {
+		u := usageFinder{pkg: pkg, iface: iface}
+		for _, f := range pkg.Files {
+			ast.Inspect(f, u.visit)
+		}
+		usages = append(usages, u.usages...)
+	}
+	return usages
+}
+
+// usageFinder finds the usages of an interface in a package.
+type usageFinder struct {
+	pkg    *Package
+	iface  types.Type
+	stack  []ast.Node // Enclosing nodes of the node being visited.
+	usages []Usage
+}
+
+func (u *usageFinder) visit(n ast.Node) bool {
+	if n == nil {
+		u.stack = u.stack[:len(u.stack)-1]
+		return true
+	}
+	u.stack = append(u.stack, n)
+
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		if n.Tok == token.ASSIGN && len(n.Lhs) == len(n.Rhs) {
+			for i, rhs := range n.Rhs {
+				u.check(rhs, u.pkg.Info.TypeOf(n.Lhs[i]), usageAssignment)
+			}
+		}
+	case *ast.ValueSpec:
+		if n.Type != nil {
+			for _, v := range n.Values {
+				u.check(v, u.pkg.Info.TypeOf(n.Type), usageAssignment)
+			}
+		}
+	case *ast.CallExpr:
+		u.call(n)
+	case *ast.ReturnStmt:
+		if sig := u.enclosingFunc(); sig != nil && sig.Results().Len() == len(n.Results) {
+			for i, res := range n.Results {
+				u.check(res, sig.Results().At(i).Type(), usageReturn)
+			}
+		}
+	case *ast.CompositeLit:
+		u.compositeLit(n)
+	}
+	return true
+}
+
+func (u *usageFinder) call(call *ast.CallExpr) {
+	tv, ok := u.pkg.Info.Types[call.Fun]
+	if !ok {
+		return
+	}
+	if tv.IsType() {
+		if len(call.Args) == 1 {
+			u.check(call.Args[0], tv.Type, usageConversion)
+		}
+		return
+	}
+	sig, ok := tv.Type.Underlying().(*types.Signature)
+	if !ok {
+		return
+	}
+	params := sig.Params()
+	for i, a := range call.Args {
+		var t types.Type
+		switch {
+		case sig.Variadic() && i >= params.Len()-1:
+			if call.Ellipsis.IsValid() {
+				// Passing a slice to a variadic parameter converts nothing.
+				continue
+			}
+			t = params.At(params.Len() - 1).Type().(*types.Slice).Elem()
+		case i < params.Len():
+			t = params.At(i).Type()
+		default:
+			continue
+		}
+		u.check(a, t, usageArgument)
+	}
+}
+
+func (u *usageFinder) compositeLit(lit *ast.CompositeLit) {
+	typ := u.pkg.Info.TypeOf(lit)
+	if typ == nil {
+		return
+	}
+	if p, ok := typ.Underlying().(*types.Pointer); ok {
+		// An elided &T in a composite literal of pointers.
+		typ = p.Elem()
+	}
+	switch t := typ.Underlying().(type) {
+	case *types.Struct:
+		for i, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				if key, ok := kv.Key.(*ast.Ident); ok {
+					if f := field(t, key.Name); f != nil {
+						u.check(kv.Value, f.Type(), usageComposite)
+					}
+				}
+			} else if i < t.NumFields() {
+				u.check(elt, t.Field(i).Type(), usageComposite)
+			}
+		}
+	case *types.Slice:
+		u.elements(lit, nil, t.Elem())
+	case *types.Array:
+		u.elements(lit, nil, t.Elem())
+	case *types.Map:
+		u.elements(lit, t.Key(), t.Elem())
+	}
+}
+
+// elements checks the elements of a slice, array, or map composite literal.
+// key is nil for slices and arrays.
+func (u *usageFinder) elements(lit *ast.CompositeLit, key, elem types.Type) {
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key != nil {
+				u.check(kv.Key, key, usageComposite)
+			}
+			elt = kv.Value
+		}
+		u.check(elt, elem, usageComposite)
+	}
+}
+
+// field returns the field of s named name, or nil.
+func field(s *types.Struct, name string) *types.Var {
+	for i := 0; i < s.NumFields(); i++ {
+		if s.Field(i).Name() == name {
+			return s.Field(i)
+		}
+	}
+	return nil
+}
+
+// enclosingFunc returns the signature of the innermost function declaration
+// or literal enclosing the node being visited.
+func (u *usageFinder) enclosingFunc() *types.Signature {
+	for i := len(u.stack) - 1; i >= 0; i-- {
+		switch n := u.stack[i].(type) {
+		case *ast.FuncLit:
+			sig, _ := u.pkg.Info.TypeOf(n).(*types.Signature)
+			return sig
+		case *ast.FuncDecl:
+			if obj := u.pkg.Info.Defs[n.Name]; obj != nil {
+				sig, _ := obj.Type().(*types.Signature)
+				return sig
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// check records a Usage if expr has a concrete type and target is the
+// interface being searched for.
+func (u *usageFinder) check(expr ast.Expr, target types.Type, kind string) {
+	if target == nil || !types.Identical(target, u.iface) {
+		return
+	}
+	tv, ok := u.pkg.Info.Types[expr]
+	if !ok || tv.Type == nil || tv.IsNil() || types.IsInterface(tv.Type) {
+		return
+	}
+	u.usages = append(u.usages, Usage{
+		Kind: kind,
+		Type: types.TypeString(tv.Type, nil),
+		Pos:  u.pkg.Fset.Position(expr.Pos()),
+		End:  u.pkg.Fset.Position(expr.End()),
+	})
+}
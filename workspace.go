@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Module is a module, as found from its go.mod file.
+type Module struct {
+	Path string
+	// Version is the version of a module in the module cache, from the
+	// name of its directory. It is "" for other modules, such as the
+	// modules of a go.work workspace, which are used from their sources.
+	Version string
+	Dir     string
+}
+
+// moduleOf returns the module of the package in dir, from the nearest
+// enclosing go.mod file, or nil if there is none.
+func moduleOf(dir string) *Module {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	mod, modDir := findModule(abs)
+	if mod == "" {
+		return nil
+	}
+	m := &Module{Path: mod, Dir: modDir}
+	// The module cache holds a module in a directory named path@version.
+	if i := strings.LastIndexByte(filepath.Base(modDir), '@'); i >= 0 {
+		m.Version = filepath.Base(modDir)[i+1:]
+	}
+	return m
+}
+
+// workspace is a go.work workspace.
+type workspace struct {
+	Dir     string    // The directory of the go.work file.
+	Modules []*Module // The modules in its use directives.
+}
+
+// findWorkspace returns the workspace that dir belongs to, as the go tool
+// finds it: from the go.work file named by the GOWORK environment variable,
+// or else from the go.work file in dir or its nearest ancestor. It returns
+// nil if there is none, or if GOWORK is off.
+func findWorkspace(dir string) *workspace {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return nil
+	case "":
+	default:
+		return parseWorkFile(gowork)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	for {
+		if ws := parseWorkFile(filepath.Join(abs, "go.work")); ws != nil {
+			return ws
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return nil
+		}
+		abs = parent
+	}
+}
+
+// parseWorkFile returns the workspace of the go.work file gowork, or nil if
+// the file does not exist. Use directives whose directory has no go.mod file
+// are skipped.
+func parseWorkFile(gowork string) *workspace {
+	f, err := os.Open(gowork)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	abs, err := filepath.Abs(filepath.Dir(gowork))
+	if err != nil {
+		return nil
+	}
+	ws := &workspace{Dir: abs}
+	use := func(dir string) {
+		if d, err := strconv.Unquote(dir); err == nil {
+			dir = d
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(ws.Dir, dir)
+		}
+		if mod := modulePath(filepath.Join(dir, "go.mod")); mod != "" {
+			ws.Modules = append(ws.Modules, &Module{Path: mod, Dir: filepath.Clean(dir)})
+		}
+	}
+	inBlock := false
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case inBlock && fields[0] == ")":
+			inBlock = false
+		case inBlock:
+			use(fields[0])
+		case fields[0] == "use" && len(fields) == 2 && fields[1] == "(":
+			inBlock = true
+		case fields[0] == "use" && len(fields) == 2:
+			use(fields[1])
+		}
+	}
+	return ws
+}
+
+// moduleDir returns the directory of the package with the import path
+// importPath in the workspace's modules, or "" if no module has it.
+func (ws *workspace) moduleDir(importPath string) string {
+	var best *Module
+	for _, m := range ws.Modules {
+		if (importPath == m.Path || strings.HasPrefix(importPath, m.Path+"/")) && (best == nil || len(m.Path) > len(best.Path)) {
+			best = m
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return filepath.Join(best.Dir, filepath.FromSlash(strings.TrimPrefix(importPath, best.Path)))
+}
+
+// workspaceRoots returns the directories that a recursive search of root
+// walks: the directories of the workspace modules if root has a go.work
+// file, as the go tool matches ./... in a workspace, and root otherwise.
+func workspaceRoots(root string) []string {
+	if os.Getenv("GOWORK") == "off" {
+		return []string{root}
+	}
+	ws := parseWorkFile(filepath.Join(root, "go.work"))
+	if ws == nil {
+		return []string{root}
+	}
+	roots := make([]string, 0, len(ws.Modules))
+	for _, m := range ws.Modules {
+		roots = append(roots, m.Dir)
+	}
+	return roots
+}
+
+// workspaceDeps parses the packages of the go.work workspace modules that
+// pkgs import, directly or through each other, and that are not in pkgs, so
+// that the imports across the modules of a workspace are type-checked from
+// their sources rather than imported from the module cache. The returned
+// packages are only loaded to type-check pkgs.
+func workspaceDeps(ctx context.Context, pkgs []*Package, fset *token.FileSet, cfg loadConfig) ([]*Package, error) {
+	loaded := make(map[string]bool)
+	for _, pkg := range pkgs {
+		if pkg.ImportPath != "" {
+			loaded[pkg.ImportPath] = true
+		}
+	}
+	workspaces := make(map[string]*workspace) // By package directory.
+	var deps []*Package
+	for queue := pkgs; len(queue) != 0; {
+		var filenames []string
+		for _, pkg := range queue {
+			ws, ok := workspaces[pkg.Dir]
+			if !ok {
+				ws = findWorkspace(pkg.Dir)
+				workspaces[pkg.Dir] = ws
+			}
+			if ws == nil {
+				continue
+			}
+			for _, f := range pkg.Files {
+				for _, spec := range f.Imports {
+					p, err := strconv.Unquote(spec.Path.Value)
+					if err != nil || loaded[p] {
+						continue
+					}
+					dir := ws.moduleDir(p)
+					if dir == "" {
+						continue
+					}
+					loaded[p] = true
+					filenames = append(filenames, depFiles(dir, cfg)...)
+				}
+			}
+		}
+		next, err := parseFiles(ctx, filenames, fset, cfg)
+		if err != nil {
+			return nil, err
+		}
+		deps = append(deps, next...)
+		queue = next
+	}
+	return deps, nil
+}
+
+// depFiles returns the go files in dir that the go tool builds for a
+// dependency: test files are left out, and the build constraints are matched
+// as cfg matches them, whatever files cfg skips otherwise. An unreadable
+// directory has none, so that the import is left to the fallback importer to
+// report.
+func depFiles(dir string, cfg loadConfig) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, d := range entries {
+		file := filepath.Join(dir, d.Name())
+		if isGoFile(d) && !strings.HasSuffix(file, "_test.go") && cfg.matchTags(file) {
+			files = append(files, file)
+		}
+	}
+	return files
+}